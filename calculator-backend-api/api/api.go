@@ -0,0 +1,95 @@
+// Package api defines what goes over the wire between the calculator server
+// and its clients: the request and response bodies, error codes and headers.
+// It has no dependencies, so that clients can import it without the server.
+package api
+
+// Headers with a meaning specific to the calculator API.
+const (
+	// RequestIDHeader carries the request ID in both requests and
+	// responses.
+	RequestIDHeader = "X-Request-ID"
+	// IdempotencyKeyHeader carries the client's key for a retryable request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set to "true" on replayed responses.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// PrecisionHeader opts a request into decimal precision mode when set
+	// to "decimal", as an alternative to the "precision" body field.
+	PrecisionHeader = "X-Precision"
+)
+
+// Request is the body accepted by /add, /subtract and /multiply.
+type Request struct {
+	A float64 `json:"number1"`
+	B float64 `json:"number2"`
+}
+
+// DivideRequest is the body accepted by /divide.
+type DivideRequest struct {
+	Dividend float64 `json:"dividend"`
+	Divisor  float64 `json:"divisor"`
+}
+
+// SumRequest is the body accepted by /sum, a bare array of numbers.
+type SumRequest []float64
+
+// Response is the body of a calculation's result, and of every error.
+type Response struct {
+	Result  float64 `json:"result"`
+	Error   string  `json:"error,omitempty"`
+	Message string  `json:"message,omitempty"`
+	// Position is the character offset of a syntax error in an /evaluate expression.
+	Position *int `json:"position,omitempty"`
+	// Fields lists the offending request fields of an invalid payload.
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes a problem with one field of a request. Field is the
+// JSON path of the field, e.g. "number1" or "[2].op", and is empty for
+// problems with the body as a whole.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ErrorCode is the stable, machine-readable identifier returned in
+// Response.Error when an operation fails. It is an error itself, so that
+// errors carrying a code can be matched with errors.Is, as in
+// errors.Is(err, api.CodeDivisionByZero).
+type ErrorCode string
+
+func (c ErrorCode) Error() string {
+	return string(c)
+}
+
+const (
+	CodeDivisionByZero    ErrorCode = "division_by_zero"
+	CodeOverflow          ErrorCode = "overflow"
+	CodeNaN               ErrorCode = "nan_result"
+	CodeOutOfRange        ErrorCode = "out_of_range"
+	CodeDomainError       ErrorCode = "domain_error"
+	CodeIncompatibleUnits ErrorCode = "incompatible_units"
+	CodeDimensionMismatch ErrorCode = "dimension_mismatch"
+	CodeSingularMatrix    ErrorCode = "singular_matrix"
+	CodeMatrixTooLarge    ErrorCode = "matrix_too_large"
+	CodeSyntaxError       ErrorCode = "syntax_error"
+	CodeInvalidDecimal    ErrorCode = "invalid_decimal"
+	CodeInvalidScale      ErrorCode = "invalid_scale"
+	CodeInvalidRounding   ErrorCode = "invalid_rounding"
+	CodeInvalidPayload    ErrorCode = "invalid_payload"
+	CodePayloadTooLarge   ErrorCode = "payload_too_large"
+	CodeUnsupportedType   ErrorCode = "unsupported_media_type"
+	CodeNotFound          ErrorCode = "not_found"
+	CodeMethodNotAllowed  ErrorCode = "method_not_allowed"
+	CodeNotAcceptable     ErrorCode = "not_acceptable"
+	CodeUnknownOp         ErrorCode = "unknown_operation"
+	CodeIdempotencyInUse  ErrorCode = "idempotency_key_in_use"
+	CodeIdempotencyReuse  ErrorCode = "idempotency_key_reused"
+	CodeBatchTooLarge     ErrorCode = "batch_too_large"
+	CodeSessionNotFound   ErrorCode = "session_not_found"
+	CodeSessionInUse      ErrorCode = "session_in_use"
+	CodeTooManySessions   ErrorCode = "too_many_sessions"
+	CodeUnauthorized      ErrorCode = "unauthorized"
+	CodeForbidden         ErrorCode = "forbidden"
+	CodeRateLimited       ErrorCode = "rate_limited"
+	CodeInternal          ErrorCode = "internal_error"
+)
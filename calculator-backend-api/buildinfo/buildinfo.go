@@ -0,0 +1,55 @@
+// Package buildinfo describes the running build of the server.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Version and Commit can be set at build time, e.g.
+//
+//	go build -ldflags "-X cloudprojects/calculator-backend-api/buildinfo.Version=1.2.0"
+//
+// Commit defaults to the VCS revision Go embeds when building from a checkout.
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+var startTime = time.Now()
+
+// Info is the build and process information reported by /version.
+type Info struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	Modified  bool      `json:"modified"`
+	GoVersion string    `json:"go_version"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// Read returns the build information of the running binary.
+func Read() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		GoVersion: runtime.Version(),
+		StartTime: startTime.UTC(),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
@@ -0,0 +1,351 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v5.28.3
+// source: calculator.proto
+
+package calculatorpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type BinaryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Number1       float64                `protobuf:"fixed64,1,opt,name=number1,proto3" json:"number1,omitempty"`
+	Number2       float64                `protobuf:"fixed64,2,opt,name=number2,proto3" json:"number2,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BinaryRequest) Reset() {
+	*x = BinaryRequest{}
+	mi := &file_calculator_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BinaryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BinaryRequest) ProtoMessage() {}
+
+func (x *BinaryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BinaryRequest.ProtoReflect.Descriptor instead.
+func (*BinaryRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *BinaryRequest) GetNumber1() float64 {
+	if x != nil {
+		return x.Number1
+	}
+	return 0
+}
+
+func (x *BinaryRequest) GetNumber2() float64 {
+	if x != nil {
+		return x.Number2
+	}
+	return 0
+}
+
+type DivideRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dividend      float64                `protobuf:"fixed64,1,opt,name=dividend,proto3" json:"dividend,omitempty"`
+	Divisor       float64                `protobuf:"fixed64,2,opt,name=divisor,proto3" json:"divisor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DivideRequest) Reset() {
+	*x = DivideRequest{}
+	mi := &file_calculator_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DivideRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DivideRequest) ProtoMessage() {}
+
+func (x *DivideRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DivideRequest.ProtoReflect.Descriptor instead.
+func (*DivideRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DivideRequest) GetDividend() float64 {
+	if x != nil {
+		return x.Dividend
+	}
+	return 0
+}
+
+func (x *DivideRequest) GetDivisor() float64 {
+	if x != nil {
+		return x.Divisor
+	}
+	return 0
+}
+
+type SumRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Numbers       []float64              `protobuf:"fixed64,1,rep,packed,name=numbers,proto3" json:"numbers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SumRequest) Reset() {
+	*x = SumRequest{}
+	mi := &file_calculator_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SumRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SumRequest) ProtoMessage() {}
+
+func (x *SumRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SumRequest.ProtoReflect.Descriptor instead.
+func (*SumRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SumRequest) GetNumbers() []float64 {
+	if x != nil {
+		return x.Numbers
+	}
+	return nil
+}
+
+type EvaluateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Expression    string                 `protobuf:"bytes,1,opt,name=expression,proto3" json:"expression,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EvaluateRequest) Reset() {
+	*x = EvaluateRequest{}
+	mi := &file_calculator_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EvaluateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvaluateRequest) ProtoMessage() {}
+
+func (x *EvaluateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvaluateRequest.ProtoReflect.Descriptor instead.
+func (*EvaluateRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *EvaluateRequest) GetExpression() string {
+	if x != nil {
+		return x.Expression
+	}
+	return ""
+}
+
+type Result struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Result        float64                `protobuf:"fixed64,1,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Result) Reset() {
+	*x = Result{}
+	mi := &file_calculator_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Result) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Result) ProtoMessage() {}
+
+func (x *Result) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Result.ProtoReflect.Descriptor instead.
+func (*Result) Descriptor() ([]byte, []int) {
+	return file_calculator_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Result) GetResult() float64 {
+	if x != nil {
+		return x.Result
+	}
+	return 0
+}
+
+var File_calculator_proto protoreflect.FileDescriptor
+
+const file_calculator_proto_rawDesc = "" +
+	"\n" +
+	"\x10calculator.proto\x12\rcalculator.v1\"C\n" +
+	"\rBinaryRequest\x12\x18\n" +
+	"\anumber1\x18\x01 \x01(\x01R\anumber1\x12\x18\n" +
+	"\anumber2\x18\x02 \x01(\x01R\anumber2\"E\n" +
+	"\rDivideRequest\x12\x1a\n" +
+	"\bdividend\x18\x01 \x01(\x01R\bdividend\x12\x18\n" +
+	"\adivisor\x18\x02 \x01(\x01R\adivisor\"&\n" +
+	"\n" +
+	"SumRequest\x12\x18\n" +
+	"\anumbers\x18\x01 \x03(\x01R\anumbers\"1\n" +
+	"\x0fEvaluateRequest\x12\x1e\n" +
+	"\n" +
+	"expression\x18\x01 \x01(\tR\n" +
+	"expression\" \n" +
+	"\x06Result\x12\x16\n" +
+	"\x06result\x18\x01 \x01(\x01R\x06result2\x85\x03\n" +
+	"\n" +
+	"Calculator\x12:\n" +
+	"\x03Add\x12\x1c.calculator.v1.BinaryRequest\x1a\x15.calculator.v1.Result\x12?\n" +
+	"\bSubtract\x12\x1c.calculator.v1.BinaryRequest\x1a\x15.calculator.v1.Result\x12?\n" +
+	"\bMultiply\x12\x1c.calculator.v1.BinaryRequest\x1a\x15.calculator.v1.Result\x12=\n" +
+	"\x06Divide\x12\x1c.calculator.v1.DivideRequest\x1a\x15.calculator.v1.Result\x127\n" +
+	"\x03Sum\x12\x19.calculator.v1.SumRequest\x1a\x15.calculator.v1.Result\x12A\n" +
+	"\bEvaluate\x12\x1e.calculator.v1.EvaluateRequest\x1a\x15.calculator.v1.ResultB3Z1cloudprojects/calculator-backend-api/calculatorpbb\x06proto3"
+
+var (
+	file_calculator_proto_rawDescOnce sync.Once
+	file_calculator_proto_rawDescData []byte
+)
+
+func file_calculator_proto_rawDescGZIP() []byte {
+	file_calculator_proto_rawDescOnce.Do(func() {
+		file_calculator_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_calculator_proto_rawDesc), len(file_calculator_proto_rawDesc)))
+	})
+	return file_calculator_proto_rawDescData
+}
+
+var file_calculator_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_calculator_proto_goTypes = []any{
+	(*BinaryRequest)(nil),   // 0: calculator.v1.BinaryRequest
+	(*DivideRequest)(nil),   // 1: calculator.v1.DivideRequest
+	(*SumRequest)(nil),      // 2: calculator.v1.SumRequest
+	(*EvaluateRequest)(nil), // 3: calculator.v1.EvaluateRequest
+	(*Result)(nil),          // 4: calculator.v1.Result
+}
+var file_calculator_proto_depIdxs = []int32{
+	0, // 0: calculator.v1.Calculator.Add:input_type -> calculator.v1.BinaryRequest
+	0, // 1: calculator.v1.Calculator.Subtract:input_type -> calculator.v1.BinaryRequest
+	0, // 2: calculator.v1.Calculator.Multiply:input_type -> calculator.v1.BinaryRequest
+	1, // 3: calculator.v1.Calculator.Divide:input_type -> calculator.v1.DivideRequest
+	2, // 4: calculator.v1.Calculator.Sum:input_type -> calculator.v1.SumRequest
+	3, // 5: calculator.v1.Calculator.Evaluate:input_type -> calculator.v1.EvaluateRequest
+	4, // 6: calculator.v1.Calculator.Add:output_type -> calculator.v1.Result
+	4, // 7: calculator.v1.Calculator.Subtract:output_type -> calculator.v1.Result
+	4, // 8: calculator.v1.Calculator.Multiply:output_type -> calculator.v1.Result
+	4, // 9: calculator.v1.Calculator.Divide:output_type -> calculator.v1.Result
+	4, // 10: calculator.v1.Calculator.Sum:output_type -> calculator.v1.Result
+	4, // 11: calculator.v1.Calculator.Evaluate:output_type -> calculator.v1.Result
+	6, // [6:12] is the sub-list for method output_type
+	0, // [0:6] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_calculator_proto_init() }
+func file_calculator_proto_init() {
+	if File_calculator_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_calculator_proto_rawDesc), len(file_calculator_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_calculator_proto_goTypes,
+		DependencyIndexes: file_calculator_proto_depIdxs,
+		MessageInfos:      file_calculator_proto_msgTypes,
+	}.Build()
+	File_calculator_proto = out.File
+	file_calculator_proto_goTypes = nil
+	file_calculator_proto_depIdxs = nil
+}
@@ -0,0 +1,5 @@
+// Package calculatorpb holds the protobuf definition of the calculator's
+// gRPC service and the code generated from it.
+package calculatorpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative calculator.proto
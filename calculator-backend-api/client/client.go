@@ -0,0 +1,265 @@
+// Package client is a Go client for the calculator API.
+//
+//	c := client.New("http://localhost:3000")
+//	c.Token = "my-api-key"
+//	result, err := c.Divide(ctx, 1, 0)
+//	if errors.Is(err, api.CodeDivisionByZero) {
+//		...
+//	}
+//
+// It depends only on the api package, not on the server.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloudprojects/calculator-backend-api/api"
+)
+
+const (
+	DefaultTimeout    = 10 * time.Second
+	DefaultMaxRetries = 3
+	DefaultBackoff    = 100 * time.Millisecond
+	// maxBackoff caps the delay between retries.
+	maxBackoff = 5 * time.Second
+)
+
+// Client calls the calculator API. Its fields may be changed after New but
+// not while requests are in flight.
+type Client struct {
+	// BaseURL is the address of the API, e.g. "http://localhost:3000".
+	BaseURL string
+	// Token is sent as a bearer token when set.
+	Token      string
+	HTTPClient *http.Client
+	// MaxRetries is how many times a request is retried after it could not
+	// be sent or got no response, or after a 429 or a 502, 503 or 504
+	// response.
+	MaxRetries int
+	// Backoff is the delay before the first retry. It doubles, with jitter,
+	// on each further attempt.
+	Backoff time.Duration
+}
+
+// New returns a Client for the API at baseURL with default settings.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		MaxRetries: DefaultMaxRetries,
+		Backoff:    DefaultBackoff,
+	}
+}
+
+// APIError is returned when the API responds with an error. errors.Is matches
+// it against its code, such as api.CodeDivisionByZero, and against any error
+// with an ErrorCode method returning the same code.
+type APIError struct {
+	StatusCode int
+	Code       api.ErrorCode
+	Message    string
+	// Fields lists the offending request fields of an invalid payload.
+	Fields    []api.FieldError
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("calculator: %d %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("calculator: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target := target.(type) {
+	case api.ErrorCode:
+		return target == e.Code
+	case interface{ ErrorCode() api.ErrorCode }:
+		return target.ErrorCode() == e.Code
+	}
+	return false
+}
+
+func (c *Client) Add(ctx context.Context, a, b float64) (float64, error) {
+	return c.calculate(ctx, "/add", api.Request{A: a, B: b})
+}
+
+func (c *Client) Subtract(ctx context.Context, a, b float64) (float64, error) {
+	return c.calculate(ctx, "/subtract", api.Request{A: a, B: b})
+}
+
+func (c *Client) Multiply(ctx context.Context, a, b float64) (float64, error) {
+	return c.calculate(ctx, "/multiply", api.Request{A: a, B: b})
+}
+
+func (c *Client) Divide(ctx context.Context, dividend, divisor float64) (float64, error) {
+	return c.calculate(ctx, "/divide", api.DivideRequest{Dividend: dividend, Divisor: divisor})
+}
+
+func (c *Client) Sum(ctx context.Context, numbers ...float64) (float64, error) {
+	if numbers == nil {
+		numbers = []float64{}
+	}
+	return c.calculate(ctx, "/sum", api.SumRequest(numbers))
+}
+
+func (c *Client) calculate(ctx context.Context, path string, req any) (float64, error) {
+	var resp api.Response
+	if err := c.post(ctx, path, req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Result, nil
+}
+
+// post sends body to path, retrying transient failures, and decodes a
+// successful response into out. Every attempt carries the same idempotency
+// key, so a retry of a request the server did complete is not run twice.
+func (c *Client) post(ctx context.Context, path string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	idempotencyKey := newIdempotencyKey()
+
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := c.do(ctx, path, payload, idempotencyKey, out)
+		if err == nil || attempt >= c.MaxRetries || !retryable(err) {
+			return unwrapTransportError(err)
+		}
+
+		delay := max(c.backoff(attempt), retryAfter)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// do makes a single attempt, returning the server's Retry-After delay along
+// with any error.
+func (c *Client) do(ctx context.Context, path string, payload []byte, idempotencyKey string, out any) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(api.IdempotencyKeyHeader, idempotencyKey)
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, &transportError{err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return 0, fmt.Errorf("calculator: decode response: %w", err)
+		}
+		return 0, nil
+	}
+	return retryAfter(resp), decodeError(resp)
+}
+
+func decodeError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, RequestID: resp.Header.Get(api.RequestIDHeader)}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	var errResp api.Response
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+		apiErr.Code = api.ErrorCode(errResp.Error)
+		apiErr.Message = errResp.Message
+		apiErr.Fields = errResp.Fields
+		return apiErr
+	}
+	// Errors from outside the handlers, e.g. a plain text 404.
+	apiErr.Message = strings.TrimSpace(string(body))
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+// transportError wraps an error from HTTPClient.Do: the request could not be
+// sent, or no response was received.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return e.err.Error() }
+
+func (e *transportError) Unwrap() error { return e.err }
+
+// unwrapTransportError returns the error from HTTPClient.Do that err wraps,
+// if any, so that callers see the same errors as with net/http.
+func unwrapTransportError(err error) error {
+	var transportErr *transportError
+	if errors.As(err, &transportErr) {
+		return transportErr.err
+	}
+	return err
+}
+
+// retryable reports whether err is worth retrying: a transport error other
+// than cancellation, or a response that is likely to succeed later. A 409
+// idempotency_key_in_use means an earlier attempt is still running, and its
+// stored response is replayed once it finishes. Other errors, such as a
+// successful response that could not be decoded, are not.
+func retryable(err error) bool {
+	var transportErr *transportError
+	if errors.As(err, &transportErr) {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		return apiErr.Code == api.CodeIdempotencyInUse
+	}
+	return false
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxBackoff)
+}
+
+// backoff returns the delay before retry number attempt+1: exponential
+// growth from Backoff with up to 50% jitter.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.Backoff << attempt
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay/2 + mathrand.N(delay/2+1)
+}
+
+// newIdempotencyKey returns a random key shared by the attempts of a request.
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
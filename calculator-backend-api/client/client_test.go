@@ -0,0 +1,237 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"cloudprojects/calculator-backend-api/api"
+	"cloudprojects/calculator-backend-api/client"
+	"cloudprojects/calculator-backend-api/handlers"
+	"golang.org/x/exp/slog"
+)
+
+// newClient returns a client for the server at url that retries without
+// waiting.
+func newClient(url string) *client.Client {
+	c := client.New(url)
+	c.Backoff = time.Millisecond
+	return c
+}
+
+// newCalculatorServer serves the real calculation handlers.
+func newCalculatorServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+	mux.Handle("POST /add", handlers.AddHandler(logger))
+	mux.Handle("POST /subtract", handlers.SubtractHandler(logger))
+	mux.Handle("POST /multiply", handlers.MultiplyHandler(logger))
+	mux.Handle("POST /divide", handlers.DivideHandler(logger))
+	mux.Handle("POST /sum", handlers.SumHandler(logger))
+	ts := httptest.NewServer(handlers.RequestIDMiddleware(mux))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestClientCalculates(t *testing.T) {
+	c := newClient(newCalculatorServer(t).URL)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() (float64, error)
+		want float64
+	}{
+		{"add", func() (float64, error) { return c.Add(ctx, 1, 2) }, 3},
+		{"subtract", func() (float64, error) { return c.Subtract(ctx, 1, 2) }, -1},
+		{"multiply", func() (float64, error) { return c.Multiply(ctx, 3, 4) }, 12},
+		{"divide", func() (float64, error) { return c.Divide(ctx, 1, 4) }, 0.25},
+		{"sum", func() (float64, error) { return c.Sum(ctx, 1, 2, 3) }, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.call()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientReturnsTypedErrors(t *testing.T) {
+	c := newClient(newCalculatorServer(t).URL)
+
+	_, err := c.Divide(context.Background(), 1, 0)
+	if !errors.Is(err, api.CodeDivisionByZero) {
+		t.Fatalf("got %v, want %s", err, api.CodeDivisionByZero)
+	}
+	if !errors.Is(err, handlers.ErrDivisionByZero) {
+		t.Errorf("%v does not match handlers.ErrDivisionByZero", err)
+	}
+	if errors.Is(err, api.CodeOverflow) {
+		t.Errorf("%v matches %s", err, api.CodeOverflow)
+	}
+
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %T, want *client.APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", apiErr.StatusCode, http.StatusBadRequest)
+	}
+	if apiErr.RequestID == "" {
+		t.Error("request ID is missing")
+	}
+}
+
+// flakyServer answers with each of statuses in turn, then with a result,
+// recording the idempotency key of every attempt. The failed attempts carry
+// code, or rate_limited if it is empty.
+type flakyServer struct {
+	mu       sync.Mutex
+	statuses []int
+	code     api.ErrorCode
+	keys     []string
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt := len(s.keys)
+	s.keys = append(s.keys, r.Header.Get(api.IdempotencyKeyHeader))
+	w.Header().Set("Content-Type", "application/json")
+	if attempt < len(s.statuses) {
+		code := s.code
+		if code == "" {
+			code = api.CodeRateLimited
+		}
+		w.WriteHeader(s.statuses[attempt])
+		fmt.Fprintf(w, `{"result":0,"error":%q,"message":"try again later"}`, code)
+		return
+	}
+	io.WriteString(w, `{"result":3}`)
+}
+
+func TestClientRetriesWithSameIdempotencyKey(t *testing.T) {
+	flaky := &flakyServer{statuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}}
+	ts := httptest.NewServer(flaky)
+	defer ts.Close()
+
+	got, err := newClient(ts.URL).Add(context.Background(), 1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 3 {
+		t.Errorf("got %v, want 3", got)
+	}
+	if len(flaky.keys) != 3 {
+		t.Fatalf("got %d attempts, want 3", len(flaky.keys))
+	}
+	for _, key := range flaky.keys {
+		if key == "" || key != flaky.keys[0] {
+			t.Fatalf("got idempotency keys %q, want the same key on every attempt", flaky.keys)
+		}
+	}
+}
+
+func TestClientGivesUpAfterMaxRetries(t *testing.T) {
+	flaky := &flakyServer{statuses: []int{503, 503, 503, 503, 503}}
+	ts := httptest.NewServer(flaky)
+	defer ts.Close()
+
+	c := newClient(ts.URL)
+	c.MaxRetries = 2
+	_, err := c.Add(context.Background(), 1, 2)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got %v, want a 503 APIError", err)
+	}
+	if len(flaky.keys) != 3 {
+		t.Errorf("got %d attempts, want 3", len(flaky.keys))
+	}
+}
+
+func TestClientRetriesConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     api.ErrorCode
+		attempts int
+	}{
+		{"earlier attempt still running", api.CodeIdempotencyInUse, 2},
+		{"other conflict", api.CodeIdempotencyReuse, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky := &flakyServer{statuses: []int{http.StatusConflict}, code: tt.code}
+			ts := httptest.NewServer(flaky)
+			defer ts.Close()
+
+			_, err := newClient(ts.URL).Add(context.Background(), 1, 2)
+			if tt.attempts > 1 && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.attempts == 1 && !errors.Is(err, tt.code) {
+				t.Fatalf("got %v, want %s", err, tt.code)
+			}
+			if len(flaky.keys) != tt.attempts {
+				t.Fatalf("got %d attempts, want %d", len(flaky.keys), tt.attempts)
+			}
+			if flaky.keys[len(flaky.keys)-1] != flaky.keys[0] {
+				t.Errorf("got idempotency keys %q, want the same key on every attempt", flaky.keys)
+			}
+		})
+	}
+}
+
+func TestClientDoesNotRetryUndecodableResponse(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		io.WriteString(w, "not json")
+	}))
+	defer ts.Close()
+
+	if _, err := newClient(ts.URL).Add(context.Background(), 1, 2); err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != 1 {
+		t.Errorf("got %d attempts, want 1", attempts)
+	}
+}
+
+// roundTripFunc is an http.RoundTripper that calls itself.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestClientRetriesTransportErrors(t *testing.T) {
+	errRefused := errors.New("connection refused")
+	attempts := 0
+	c := newClient("http://calculator.invalid")
+	c.HTTPClient = &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		attempts++
+		return nil, errRefused
+	})}
+
+	_, err := c.Add(context.Background(), 1, 2)
+	if !errors.Is(err, errRefused) {
+		t.Fatalf("got %v, want %v", err, errRefused)
+	}
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		t.Errorf("got an APIError for a transport error: %v", apiErr)
+	}
+	if want := client.DefaultMaxRetries + 1; attempts != want {
+		t.Errorf("got %d attempts, want %d", attempts, want)
+	}
+}
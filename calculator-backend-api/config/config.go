@@ -0,0 +1,318 @@
+// Package config loads the calculator server's settings. Each setting can
+// come from a YAML file, an environment variable or a command line flag;
+// flags override environment variables, which override the file.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	ListenAddr  string   `yaml:"listen_addr"`
+	GRPCAddr    string   `yaml:"grpc_addr"`
+	CORSOrigins []string `yaml:"cors_origins"`
+
+	LogLevel  string `yaml:"log_level"`
+	LogFormat string `yaml:"log_format"`
+	// Level is LogLevel parsed, set by Load.
+	Level slog.Level `yaml:"-"`
+
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	MaxBatchSize      int   `yaml:"max_batch_size"`
+	MaxMatrixElements int   `yaml:"max_matrix_elements"`
+	MaxBodyBytes      int64 `yaml:"max_body_bytes"`
+
+	ResultCacheSize    int           `yaml:"result_cache_size"`
+	IdempotencyTTL     time.Duration `yaml:"idempotency_ttl"`
+	IdempotencyMaxKeys int           `yaml:"idempotency_max_keys"`
+
+	SessionTTL  time.Duration `yaml:"session_ttl"`
+	MaxSessions int           `yaml:"max_sessions"`
+
+	HistoryFile       string        `yaml:"history_file"`
+	HistoryMaxEntries int           `yaml:"history_max_entries"`
+	HistoryMaxAge     time.Duration `yaml:"history_max_age"`
+	AuthFile          string        `yaml:"auth_file"`
+
+	RateLimit      float64  `yaml:"rate_limit"`
+	RateBurst      int      `yaml:"rate_burst"`
+	IPRateLimit    float64  `yaml:"ip_rate_limit"`
+	IPRateBurst    int      `yaml:"ip_rate_burst"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// Default returns the settings used when nothing overrides them.
+func Default() Config {
+	return Config{
+		ListenAddr:         ":3000",
+		GRPCAddr:           ":50051",
+		CORSOrigins:        []string{"*"},
+		LogLevel:           "info",
+		Level:              slog.LevelInfo,
+		LogFormat:          "text",
+		ReadTimeout:        5 * time.Second,
+		WriteTimeout:       10 * time.Second,
+		IdleTimeout:        60 * time.Second,
+		ShutdownTimeout:    15 * time.Second,
+		MaxBatchSize:       1000,
+		MaxMatrixElements:  10000,
+		MaxBodyBytes:       1 << 20,
+		ResultCacheSize:    10000,
+		IdempotencyTTL:     24 * time.Hour,
+		IdempotencyMaxKeys: 10000,
+		SessionTTL:         15 * time.Minute,
+		MaxSessions:        100,
+		HistoryFile:        "history.jsonl",
+		HistoryMaxEntries:  100000,
+		RateLimit:          10,
+		RateBurst:          20,
+		IPRateLimit:        50,
+		IPRateBurst:        100,
+	}
+}
+
+// setting is a value that can be set from an environment variable or flag.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	set   func(cfg *Config, value string) error
+}
+
+var settings = []setting{
+	{"listen-addr", "CALCULATOR_LISTEN_ADDR", "address to listen on", func(c *Config, v string) error {
+		c.ListenAddr = v
+		return nil
+	}},
+	{"grpc-addr", "CALCULATOR_GRPC_ADDR", "address to serve gRPC on, disabled when empty", func(c *Config, v string) error {
+		c.GRPCAddr = v
+		return nil
+	}},
+	{"cors-origins", "CALCULATOR_CORS_ORIGINS", "comma separated list of allowed CORS origins", func(c *Config, v string) error {
+		c.CORSOrigins = splitList(v)
+		return nil
+	}},
+	{"log-level", "CALCULATOR_LOG_LEVEL", "log level: debug, info, warn or error", func(c *Config, v string) error {
+		c.LogLevel = v
+		return nil
+	}},
+	{"log-format", "CALCULATOR_LOG_FORMAT", "log format: text or json", func(c *Config, v string) error {
+		c.LogFormat = v
+		return nil
+	}},
+	{"read-timeout", "CALCULATOR_READ_TIMEOUT", "maximum duration for reading a request", durationSetter(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+	{"write-timeout", "CALCULATOR_WRITE_TIMEOUT", "maximum duration for writing a response", durationSetter(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+	{"idle-timeout", "CALCULATOR_IDLE_TIMEOUT", "maximum time to keep an idle connection open", durationSetter(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+	{"shutdown-timeout", "CALCULATOR_SHUTDOWN_TIMEOUT", "maximum time to drain in-flight requests on shutdown", durationSetter(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{"max-batch-size", "CALCULATOR_MAX_BATCH_SIZE", "maximum number of items in a /batch request", func(c *Config, v string) (err error) {
+		c.MaxBatchSize, err = strconv.Atoi(v)
+		return err
+	}},
+	{"max-matrix-elements", "CALCULATOR_MAX_MATRIX_ELEMENTS", "maximum number of elements in a matrix, input or result", func(c *Config, v string) (err error) {
+		c.MaxMatrixElements, err = strconv.Atoi(v)
+		return err
+	}},
+	{"max-body-bytes", "CALCULATOR_MAX_BODY_BYTES", "maximum size of a request body in bytes", func(c *Config, v string) (err error) {
+		c.MaxBodyBytes, err = strconv.ParseInt(v, 10, 64)
+		return err
+	}},
+	{"result-cache-size", "CALCULATOR_RESULT_CACHE_SIZE", "number of recent results to cache, 0 disables the cache", func(c *Config, v string) (err error) {
+		c.ResultCacheSize, err = strconv.Atoi(v)
+		return err
+	}},
+	{"idempotency-ttl", "CALCULATOR_IDEMPOTENCY_TTL", "how long responses are kept for replay by Idempotency-Key", durationSetter(func(c *Config) *time.Duration { return &c.IdempotencyTTL })},
+	{"idempotency-max-keys", "CALCULATOR_IDEMPOTENCY_MAX_KEYS", "maximum number of idempotency keys remembered", func(c *Config, v string) (err error) {
+		c.IdempotencyMaxKeys, err = strconv.Atoi(v)
+		return err
+	}},
+	{"session-ttl", "CALCULATOR_SESSION_TTL", "how long an idle /ws session is kept", durationSetter(func(c *Config) *time.Duration { return &c.SessionTTL })},
+	{"max-sessions", "CALCULATOR_MAX_SESSIONS", "maximum number of concurrent /ws sessions", func(c *Config, v string) (err error) {
+		c.MaxSessions, err = strconv.Atoi(v)
+		return err
+	}},
+	{"history-file", "CALCULATOR_HISTORY_FILE", "file the calculation history is stored in", func(c *Config, v string) error {
+		c.HistoryFile = v
+		return nil
+	}},
+	{"history-max-entries", "CALCULATOR_HISTORY_MAX_ENTRIES", "number of calculations kept in the history, 0 keeps all", func(c *Config, v string) (err error) {
+		c.HistoryMaxEntries, err = strconv.Atoi(v)
+		return err
+	}},
+	{"history-max-age", "CALCULATOR_HISTORY_MAX_AGE", "how long calculations are kept in the history, 0 keeps them until deleted", durationSetter(func(c *Config) *time.Duration { return &c.HistoryMaxAge })},
+	{"auth-file", "CALCULATOR_AUTH_FILE", "JSON file of API keys and JWT settings, authentication is disabled when empty", func(c *Config, v string) error {
+		c.AuthFile = v
+		return nil
+	}},
+	{"rate-limit", "CALCULATOR_RATE_LIMIT", "requests per second allowed per client", func(c *Config, v string) (err error) {
+		c.RateLimit, err = strconv.ParseFloat(v, 64)
+		return err
+	}},
+	{"rate-burst", "CALCULATOR_RATE_BURST", "requests a client may make at once", func(c *Config, v string) (err error) {
+		c.RateBurst, err = strconv.Atoi(v)
+		return err
+	}},
+	{"ip-rate-limit", "CALCULATOR_IP_RATE_LIMIT", "requests per second allowed per IP address before authentication", func(c *Config, v string) (err error) {
+		c.IPRateLimit, err = strconv.ParseFloat(v, 64)
+		return err
+	}},
+	{"ip-rate-burst", "CALCULATOR_IP_RATE_BURST", "requests an IP address may make at once before authentication", func(c *Config, v string) (err error) {
+		c.IPRateBurst, err = strconv.Atoi(v)
+		return err
+	}},
+	{"trusted-proxies", "CALCULATOR_TRUSTED_PROXIES", "comma separated addresses or CIDR ranges whose X-Forwarded-For is trusted", func(c *Config, v string) error {
+		c.TrustedProxies = splitList(v)
+		return nil
+	}},
+}
+
+func durationSetter(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+func splitList(v string) []string {
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Load builds the configuration from the YAML file named by the -config flag
+// or CALCULATOR_CONFIG, then environment variables, then the flags in args.
+func Load(args []string) (Config, error) {
+	fs := flag.NewFlagSet("calculator", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CALCULATOR_CONFIG"), "YAML config file (env CALCULATOR_CONFIG)")
+
+	// Flags are only recorded while parsing, and applied after the file
+	// and environment so that they take precedence.
+	type flagValue struct {
+		setting setting
+		value   string
+	}
+	var flagValues []flagValue
+	for _, s := range settings {
+		fs.Func(s.flag, fmt.Sprintf("%s (env %s)", s.usage, s.env), func(v string) error {
+			flagValues = append(flagValues, flagValue{s, v})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := loadFile(&cfg, *configFile); err != nil {
+			return Config{}, err
+		}
+	}
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok {
+			if err := s.set(&cfg, v); err != nil {
+				return Config{}, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+	for _, fv := range flagValues {
+		if err := fv.setting.set(&cfg, fv.value); err != nil {
+			return Config{}, fmt.Errorf("invalid -%s: %w", fv.setting.flag, err)
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate checks the settings, and parses those that need it.
+func (c *Config) validate() error {
+	switch strings.ToLower(c.LogFormat) {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid log format %q, must be text or json", c.LogFormat)
+	}
+	if err := c.Level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return fmt.Errorf("invalid log level %q, must be debug, info, warn or error: %w", c.LogLevel, err)
+	}
+	if c.MaxBatchSize < 1 {
+		return fmt.Errorf("max batch size must be at least 1, got %d", c.MaxBatchSize)
+	}
+	if c.MaxMatrixElements < 1 {
+		return fmt.Errorf("max matrix elements must be at least 1, got %d", c.MaxMatrixElements)
+	}
+	if c.MaxBodyBytes < 1 {
+		return fmt.Errorf("max body bytes must be at least 1, got %d", c.MaxBodyBytes)
+	}
+	if c.ResultCacheSize < 0 {
+		return fmt.Errorf("result cache size must not be negative, got %d", c.ResultCacheSize)
+	}
+	if c.IdempotencyTTL <= 0 {
+		return fmt.Errorf("idempotency TTL must be positive, got %s", c.IdempotencyTTL)
+	}
+	if c.IdempotencyMaxKeys < 1 {
+		return fmt.Errorf("idempotency max keys must be at least 1, got %d", c.IdempotencyMaxKeys)
+	}
+	if c.HistoryMaxEntries < 0 {
+		return fmt.Errorf("history max entries must not be negative, got %d", c.HistoryMaxEntries)
+	}
+	if c.HistoryMaxAge < 0 {
+		return fmt.Errorf("history max age must not be negative, got %s", c.HistoryMaxAge)
+	}
+	if c.SessionTTL <= 0 {
+		return fmt.Errorf("session TTL must be positive, got %s", c.SessionTTL)
+	}
+	if c.MaxSessions < 1 {
+		return fmt.Errorf("max sessions must be at least 1, got %d", c.MaxSessions)
+	}
+	if c.ListenAddr == "" {
+		return errors.New("listen address must not be empty")
+	}
+	for name, d := range map[string]time.Duration{
+		"read timeout":     c.ReadTimeout,
+		"write timeout":    c.WriteTimeout,
+		"idle timeout":     c.IdleTimeout,
+		"shutdown timeout": c.ShutdownTimeout,
+	} {
+		if d <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", name, d)
+		}
+	}
+	return nil
+}
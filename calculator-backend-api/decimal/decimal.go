@@ -0,0 +1,176 @@
+// Package decimal implements exact, arbitrary-precision decimal arithmetic
+// for the calculator's precision mode. Values are stored as an unscaled
+// integer and a scale, so 0.1 + 0.2 is exactly 0.3.
+package decimal
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// MaxDigits is the longest number, in digits, that Parse accepts.
+const MaxDigits = 1000
+
+// MaxScale is the largest number of fractional digits a value may be rounded
+// or divided to.
+const MaxScale = 1000
+
+var (
+	ErrSyntax         = errors.New("invalid decimal number")
+	ErrTooLarge       = errors.New("decimal number has too many digits")
+	ErrScale          = errors.New("scale out of range")
+	ErrDivisionByZero = errors.New("division by zero")
+)
+
+// Decimal is an immutable decimal number equal to unscaled * 10^-scale.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+var ten = big.NewInt(10)
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(ten, big.NewInt(int64(n)), nil)
+}
+
+// Parse parses a decimal string such as "-12.50" or "1.5e3".
+func Parse(s string) (Decimal, error) {
+	mantissa, exponent := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa = s[:i]
+		exp := s[i+1:]
+		if len(exp) == 0 || len(exp) > 5 {
+			return Decimal{}, ErrSyntax
+		}
+		for j, c := range exp {
+			if (c == '+' || c == '-') && j == 0 && len(exp) > 1 {
+				continue
+			}
+			if c < '0' || c > '9' {
+				return Decimal{}, ErrSyntax
+			}
+		}
+		var e big.Int
+		e.SetString(exp, 10)
+		exponent = int(e.Int64())
+	}
+
+	digits := strings.TrimLeft(mantissa, "+-")
+	if len(mantissa)-len(digits) > 1 {
+		return Decimal{}, ErrSyntax
+	}
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart == "" && fracPart == "" {
+		return Decimal{}, ErrSyntax
+	}
+	for _, c := range intPart + fracPart {
+		if c < '0' || c > '9' {
+			return Decimal{}, ErrSyntax
+		}
+	}
+	if len(intPart)+len(fracPart) > MaxDigits {
+		return Decimal{}, ErrTooLarge
+	}
+
+	unscaled, ok := new(big.Int).SetString(intPart+fracPart, 10)
+	if !ok {
+		return Decimal{}, ErrSyntax
+	}
+	if strings.HasPrefix(mantissa, "-") {
+		unscaled.Neg(unscaled)
+	}
+
+	scale := len(fracPart) - exponent
+	if scale < 0 {
+		if len(intPart)+len(fracPart)-scale > MaxDigits {
+			return Decimal{}, ErrTooLarge
+		}
+		unscaled.Mul(unscaled, pow10(-scale))
+		scale = 0
+	}
+	if scale > MaxDigits {
+		return Decimal{}, ErrTooLarge
+	}
+	return Decimal{unscaled: unscaled, scale: scale}, nil
+}
+
+// String formats d with exactly Scale fractional digits.
+func (d Decimal) String() string {
+	if d.unscaled == nil {
+		return "0"
+	}
+	digits := new(big.Int).Abs(d.unscaled).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if d.unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Scale returns the number of fractional digits in d.
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+func (d Decimal) int() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+// rescale returns the unscaled value of d expressed at a larger scale.
+func (d Decimal) rescale(scale int) *big.Int {
+	return new(big.Int).Mul(d.int(), pow10(scale-d.scale))
+}
+
+func (d Decimal) Add(e Decimal) Decimal {
+	scale := max(d.scale, e.scale)
+	return Decimal{unscaled: new(big.Int).Add(d.rescale(scale), e.rescale(scale)), scale: scale}
+}
+
+func (d Decimal) Sub(e Decimal) Decimal {
+	scale := max(d.scale, e.scale)
+	return Decimal{unscaled: new(big.Int).Sub(d.rescale(scale), e.rescale(scale)), scale: scale}
+}
+
+func (d Decimal) Mul(e Decimal) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(d.int(), e.int()), scale: d.scale + e.scale}
+}
+
+// Quo returns d / e rounded to scale fractional digits using mode.
+func (d Decimal) Quo(e Decimal, scale int, mode RoundingMode) (Decimal, error) {
+	if scale < 0 || scale > MaxScale {
+		return Decimal{}, ErrScale
+	}
+	if e.int().Sign() == 0 {
+		return Decimal{}, ErrDivisionByZero
+	}
+	// d/e = (dU * 10^-dS) / (eU * 10^-eS); scaling the numerator by
+	// 10^(scale+eS-dS) yields the quotient's unscaled value at scale.
+	num, den := new(big.Int).Set(d.int()), new(big.Int).Set(e.int())
+	if shift := scale + e.scale - d.scale; shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+	return Decimal{unscaled: roundQuo(num, den, mode), scale: scale}, nil
+}
+
+// Round returns d rounded, or zero-padded, to exactly scale fractional digits.
+func (d Decimal) Round(scale int, mode RoundingMode) (Decimal, error) {
+	if scale < 0 || scale > MaxScale {
+		return Decimal{}, ErrScale
+	}
+	if scale >= d.scale {
+		return Decimal{unscaled: d.rescale(scale), scale: scale}, nil
+	}
+	return Decimal{unscaled: roundQuo(d.int(), pow10(d.scale-scale), mode), scale: scale}, nil
+}
@@ -0,0 +1,182 @@
+package decimal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) Decimal {
+	t.Helper()
+	d, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", s, err)
+	}
+	return d
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"0", "0"},
+		{"-12.50", "-12.50"},
+		{"+3", "3"},
+		{".5", "0.5"},
+		{"5.", "5"},
+		{"0.000", "0.000"},
+		{"1.5e3", "1500"},
+		{"1E2", "100"},
+		{"1.5e-3", "0.0015"},
+		{"-25e-1", "-2.5"},
+		{"1e+2", "100"},
+		{strings.Repeat("9", MaxDigits), strings.Repeat("9", MaxDigits)},
+	}
+	for _, tt := range tests {
+		if got := mustParse(t, tt.input).String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  error
+	}{
+		{"", ErrSyntax},
+		{".", ErrSyntax},
+		{"abc", ErrSyntax},
+		{"1.2.3", ErrSyntax},
+		{"--1", ErrSyntax},
+		{"1-", ErrSyntax},
+		{"1e", ErrSyntax},
+		{"1e+", ErrSyntax},
+		{"e5", ErrSyntax},
+		{"1e123456", ErrSyntax},
+		{"0x10", ErrSyntax},
+		{strings.Repeat("9", MaxDigits+1), ErrTooLarge},
+		{"1e1000", ErrTooLarge},
+		{"1e-1001", ErrTooLarge},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.input); !errors.Is(err, tt.want) {
+			t.Errorf("Parse(%.20q): got %v, want %v", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		a, b           string
+		sum, diff, mul string
+	}{
+		{"0.1", "0.2", "0.3", "-0.1", "0.02"},
+		{"1.50", "2.5", "4.00", "-1.00", "3.750"},
+		{"-3", "0.001", "-2.999", "-3.001", "-0.003"},
+		{"99999999999999999999", "1", "100000000000000000000", "99999999999999999998", "99999999999999999999"},
+	}
+	for _, tt := range tests {
+		a, b := mustParse(t, tt.a), mustParse(t, tt.b)
+		if got := a.Add(b).String(); got != tt.sum {
+			t.Errorf("%s + %s = %s, want %s", tt.a, tt.b, got, tt.sum)
+		}
+		if got := a.Sub(b).String(); got != tt.diff {
+			t.Errorf("%s - %s = %s, want %s", tt.a, tt.b, got, tt.diff)
+		}
+		if got := a.Mul(b).String(); got != tt.mul {
+			t.Errorf("%s * %s = %s, want %s", tt.a, tt.b, got, tt.mul)
+		}
+	}
+}
+
+func TestQuo(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		scale int
+		mode  RoundingMode
+		want  string
+	}{
+		{"1", "3", 5, HalfEven, "0.33333"},
+		{"2", "3", 5, HalfEven, "0.66667"},
+		{"2", "3", 5, Down, "0.66666"},
+		{"-2", "3", 5, Floor, "-0.66667"},
+		{"-2", "3", 5, Ceiling, "-0.66666"},
+		{"1", "-8", 2, HalfEven, "-0.12"},
+		{"1", "-8", 2, HalfUp, "-0.13"},
+		{"10", "4", 0, HalfEven, "2"},
+		{"10", "4", 0, HalfUp, "3"},
+		{"0.01", "0.1", 3, HalfEven, "0.100"},
+		{"123.456", "0.001", 0, HalfEven, "123456"},
+	}
+	for _, tt := range tests {
+		got, err := mustParse(t, tt.a).Quo(mustParse(t, tt.b), tt.scale, tt.mode)
+		if err != nil {
+			t.Errorf("%s / %s: %v", tt.a, tt.b, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s / %s at scale %d %s = %s, want %s", tt.a, tt.b, tt.scale, tt.mode, got, tt.want)
+		}
+	}
+
+	one := mustParse(t, "1")
+	if _, err := one.Quo(mustParse(t, "0.00"), 2, HalfEven); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("division by zero: got %v", err)
+	}
+	for _, scale := range []int{-1, MaxScale + 1} {
+		if _, err := one.Quo(one, scale, HalfEven); !errors.Is(err, ErrScale) {
+			t.Errorf("Quo at scale %d: got %v, want ErrScale", scale, err)
+		}
+		if _, err := one.Round(scale, HalfEven); !errors.Is(err, ErrScale) {
+			t.Errorf("Round at scale %d: got %v, want ErrScale", scale, err)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	inputs := []string{"5.5", "2.5", "1.6", "1.1", "-1.1", "-1.6", "-2.5", "-5.5"}
+	tests := []struct {
+		mode RoundingMode
+		want []string
+	}{
+		{Up, []string{"6", "3", "2", "2", "-2", "-2", "-3", "-6"}},
+		{Down, []string{"5", "2", "1", "1", "-1", "-1", "-2", "-5"}},
+		{Ceiling, []string{"6", "3", "2", "2", "-1", "-1", "-2", "-5"}},
+		{Floor, []string{"5", "2", "1", "1", "-2", "-2", "-3", "-6"}},
+		{HalfUp, []string{"6", "3", "2", "1", "-1", "-2", "-3", "-6"}},
+		{HalfDown, []string{"5", "2", "2", "1", "-1", "-2", "-2", "-5"}},
+		{HalfEven, []string{"6", "2", "2", "1", "-1", "-2", "-2", "-6"}},
+	}
+	for _, tt := range tests {
+		for i, input := range inputs {
+			got, err := mustParse(t, input).Round(0, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want[i] {
+				t.Errorf("Round(%s, 0, %s) = %s, want %s", input, tt.mode, got, tt.want[i])
+			}
+		}
+	}
+
+	// Rounding to more digits pads with zeros.
+	got, err := mustParse(t, "1.5").Round(3, HalfEven)
+	if err != nil || got.String() != "1.500" || got.Scale() != 3 {
+		t.Errorf("Round(1.5, 3) = %s, %v, want 1.500", got, err)
+	}
+}
+
+func TestParseRoundingMode(t *testing.T) {
+	for name, want := range map[string]RoundingMode{"": HalfEven, "half_up": HalfUp, "floor": Floor} {
+		if got, err := ParseRoundingMode(name); err != nil || got != want {
+			t.Errorf("ParseRoundingMode(%q) = %s, %v, want %s", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"HALF_UP", "nearest", " up"} {
+		if _, err := ParseRoundingMode(name); err == nil {
+			t.Errorf("ParseRoundingMode(%q) succeeded", name)
+		}
+	}
+}
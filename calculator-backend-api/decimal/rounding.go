@@ -0,0 +1,67 @@
+package decimal
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// RoundingMode selects how a result is rounded when digits are discarded.
+type RoundingMode string
+
+const (
+	HalfUp   RoundingMode = "half_up"   // to nearest, ties away from zero
+	HalfDown RoundingMode = "half_down" // to nearest, ties toward zero
+	HalfEven RoundingMode = "half_even" // to nearest, ties to the even neighbour
+	Up       RoundingMode = "up"        // away from zero
+	Down     RoundingMode = "down"      // toward zero
+	Ceiling  RoundingMode = "ceiling"   // toward positive infinity
+	Floor    RoundingMode = "floor"     // toward negative infinity
+)
+
+// ParseRoundingMode parses a rounding mode name, defaulting to HalfEven when
+// the name is empty.
+func ParseRoundingMode(name string) (RoundingMode, error) {
+	switch mode := RoundingMode(name); mode {
+	case "":
+		return HalfEven, nil
+	case HalfUp, HalfDown, HalfEven, Up, Down, Ceiling, Floor:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown rounding mode %q", name)
+}
+
+// roundQuo returns num / den rounded to an integer using mode.
+func roundQuo(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+
+	// sign is the sign of the exact quotient; half compares the discarded
+	// remainder against one half of the divisor.
+	sign := int64(num.Sign() * den.Sign())
+	rem := new(big.Int).Abs(r)
+	half := rem.Lsh(rem, 1).CmpAbs(den)
+
+	var away bool
+	switch mode {
+	case Up:
+		away = true
+	case Down:
+		away = false
+	case Ceiling:
+		away = sign > 0
+	case Floor:
+		away = sign < 0
+	case HalfUp:
+		away = half >= 0
+	case HalfDown:
+		away = half > 0
+	default:
+		away = half > 0 || (half == 0 && q.Bit(0) == 1)
+	}
+	if away {
+		q.Add(q, big.NewInt(sign))
+	}
+	return q
+}
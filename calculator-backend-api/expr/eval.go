@@ -0,0 +1,146 @@
+package expr
+
+import (
+	"math"
+	"strconv"
+)
+
+// Node is a parsed expression.
+type Node interface {
+	Eval() (float64, error)
+}
+
+type numberNode float64
+
+func (n numberNode) Eval() (float64, error) {
+	return float64(n), nil
+}
+
+type negateNode struct {
+	operand Node
+}
+
+func (n *negateNode) Eval() (float64, error) {
+	v, err := n.operand.Eval()
+	if err != nil {
+		return 0, err
+	}
+	return -v, nil
+}
+
+type binaryNode struct {
+	op          string
+	pos         int
+	left, right Node
+}
+
+func (n *binaryNode) Eval() (float64, error) {
+	a, err := n.left.Eval()
+	if err != nil {
+		return 0, err
+	}
+	b, err := n.right.Eval()
+	if err != nil {
+		return 0, err
+	}
+	var result float64
+	switch n.op {
+	case "+":
+		result = a + b
+	case "-":
+		result = a - b
+	case "*":
+		result = a * b
+	case "/":
+		if b == 0 {
+			return 0, ErrDivisionByZero
+		}
+		result = a / b
+	case "^":
+		result = math.Pow(a, b)
+	default:
+		return 0, &SyntaxError{Pos: n.pos, Msg: "unknown operator " + strconv.Quote(n.op)}
+	}
+	return checked(result)
+}
+
+type callNode struct {
+	name string
+	fn   function
+	args []Node
+}
+
+func (n *callNode) Eval() (float64, error) {
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.Eval()
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	return checked(n.fn.call(args))
+}
+
+// checked rejects infinite and NaN values, so that they are reported at the
+// step that produced them rather than lost in later steps, as in 1/(1e308*10).
+func checked(v float64) (float64, error) {
+	switch {
+	case math.IsNaN(v):
+		return 0, ErrNaN
+	case math.IsInf(v, 0):
+		return 0, ErrOverflow
+	}
+	return v, nil
+}
+
+// function is an entry in the whitelist of callable functions. A maxArgs of
+// -1 means the function is variadic.
+type function struct {
+	minArgs, maxArgs int
+	call             func(args []float64) float64
+}
+
+func (f function) arity() string {
+	switch {
+	case f.maxArgs < 0:
+		return "at least " + strconv.Itoa(f.minArgs) + " argument(s)"
+	case f.minArgs == f.maxArgs:
+		return strconv.Itoa(f.minArgs) + " argument(s)"
+	}
+	return strconv.Itoa(f.minArgs) + " to " + strconv.Itoa(f.maxArgs) + " arguments"
+}
+
+var functions = map[string]function{
+	"sqrt": {1, 1, func(args []float64) float64 { return math.Sqrt(args[0]) }},
+	"abs":  {1, 1, func(args []float64) float64 { return math.Abs(args[0]) }},
+	"min": {1, -1, func(args []float64) float64 {
+		result := args[0]
+		for _, v := range args[1:] {
+			result = math.Min(result, v)
+		}
+		return result
+	}},
+	"max": {1, -1, func(args []float64) float64 {
+		result := args[0]
+		for _, v := range args[1:] {
+			result = math.Max(result, v)
+		}
+		return result
+	}},
+	// round(x) rounds to the nearest integer; round(x, n) rounds to n decimal places.
+	"round": {1, 2, func(args []float64) float64 {
+		if len(args) == 1 {
+			return math.Round(args[0])
+		}
+		// Beyond ±308 places, 10^n is not a finite, non-zero float64.
+		x, n := args[0], math.Max(-308, math.Min(308, math.Trunc(args[1])))
+		scale := math.Pow(10, n)
+		scaled := x * scale
+		if math.IsInf(scaled, 0) || math.Abs(scaled) >= 1<<53 {
+			// x has no digits left to round at n places.
+			return x
+		}
+		return math.Round(scaled) / scale
+	}},
+}
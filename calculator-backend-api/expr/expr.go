@@ -0,0 +1,53 @@
+// Package expr parses and evaluates arithmetic expressions such as
+// "(3 + 4) * 2 / 7" or "sqrt(2) ^ 2".
+//
+// The grammar, from lowest to highest precedence:
+//
+//	expression = term { ("+" | "-") term }
+//	term       = unary { ("*" | "/") unary }
+//	unary      = ("-" | "+") unary | power
+//	power      = primary [ "^" unary ]
+//	primary    = number | function "(" expression { "," expression } ")" | "(" expression ")"
+//
+// Exponentiation is right-associative and binds tighter than unary minus, so
+// -2^2 evaluates to -4.
+package expr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxLength is the longest expression, in characters, that Parse accepts.
+const MaxLength = 4096
+
+// maxDepth bounds the nesting of parentheses and unary operators.
+const maxDepth = 100
+
+var (
+	ErrDivisionByZero = errors.New("division by zero")
+	// ErrOverflow and ErrNaN report a step of the evaluation, not just the
+	// final one, whose result is infinite or not a number.
+	ErrOverflow = errors.New("result overflows the range of a float64")
+	ErrNaN      = errors.New("result is not a number")
+)
+
+// SyntaxError reports a malformed expression. Pos is the zero-based character
+// offset of the offending token.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+// Evaluate parses and evaluates an expression.
+func Evaluate(input string) (float64, error) {
+	node, err := Parse(input)
+	if err != nil {
+		return 0, err
+	}
+	return node.Eval()
+}
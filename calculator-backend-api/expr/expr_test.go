@@ -0,0 +1,155 @@
+package expr
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens, err := tokenize(" 1.5e-3*max(2, .5) ^-x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []token{
+		{kind: tokenNumber, text: "1.5e-3", num: 1.5e-3, pos: 1},
+		{kind: tokenOperator, text: "*", pos: 7},
+		{kind: tokenIdent, text: "max", pos: 8},
+		{kind: tokenLParen, text: "(", pos: 11},
+		{kind: tokenNumber, text: "2", num: 2, pos: 12},
+		{kind: tokenComma, text: ",", pos: 13},
+		{kind: tokenNumber, text: ".5", num: 0.5, pos: 15},
+		{kind: tokenRParen, text: ")", pos: 17},
+		{kind: tokenOperator, text: "^", pos: 19},
+		{kind: tokenOperator, text: "-", pos: 20},
+		{kind: tokenIdent, text: "x", pos: 21},
+		{kind: tokenEOF, pos: 22},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens %+v, want %d", len(tokens), tokens, len(want))
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("token %d: got %+v, want %+v", i, tokens[i], want[i])
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"64 / 4 / 2", 8},
+		{"2 ^ 3 ^ 2", 512},
+		{"(2 ^ 3) ^ 2", 64},
+		{"-2 ^ 2", -4},
+		{"(-2) ^ 2", 4},
+		{"2 ^ -1", 0.5},
+		{"2 * -3", -6},
+		{"--3", 3},
+		{"+-+3", -3},
+		{"1.5e3 + .5", 1500.5},
+		{"sqrt(2) ^ 2", 2.0000000000000004},
+		{"abs(-3) + min(4, 2, 8) * max(1, 5)", 13},
+		{"round(2.5)", 3},
+		{"round(1234.5678, 2)", 1234.57},
+		{"round(1234.5678, 2.9)", 1234.57},
+		{"round(1234.5678, -2)", 1200},
+		{"round(1.5, 400)", 1.5},
+		{"round(1.5, 308)", 1.5},
+		{"round(1e300, 20)", 1e300},
+		{"round(1234, -400)", 0},
+	}
+	for _, tt := range tests {
+		got, err := Evaluate(tt.input)
+		if err != nil {
+			t.Errorf("Evaluate(%q): %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Evaluate(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  error
+	}{
+		{"1 / 0", ErrDivisionByZero},
+		{"1 / (2 - 2)", ErrDivisionByZero},
+		{"1e308 * 10", ErrOverflow},
+		{"1 / (1e308 * 10)", ErrOverflow},
+		{"0 * (1e308 * 10)", ErrOverflow},
+		{"sqrt(-1)", ErrNaN},
+		{"0 * sqrt(-1)", ErrNaN},
+		{"max(1, 2 ^ 2000)", ErrOverflow},
+	}
+	for _, tt := range tests {
+		if _, err := Evaluate(tt.input); !errors.Is(err, tt.want) {
+			t.Errorf("Evaluate(%q): got %v, want %v", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{"", 0, "empty expression"},
+		{"   ", 0, "empty expression"},
+		{"1 +", 3, "unexpected end of expression"},
+		{"1 + * 2", 4, `unexpected "*"`},
+		{"2 $ 3", 2, "unexpected character '$'"},
+		{"1..2", 0, `invalid number "1..2"`},
+		{"(1 + 2", 6, `expected ")" to close "(" at position 0`},
+		{"1 + 2)", 5, `unexpected ")"`},
+		{"2 (3)", 2, `unexpected "("`},
+		{"foo(1)", 0, `unknown function "foo"`},
+		{"sqrt 2", 5, `expected "(" after sqrt`},
+		{"sqrt(1, 2)", 0, "sqrt expects 1 argument(s)"},
+		{"round(1, 2, 3)", 0, "round expects 1 to 2 arguments"},
+		{"max(1, 2", 8, "expected \")\" to close call to max"},
+		{strings.Repeat("(", maxDepth+1) + "1" + strings.Repeat(")", maxDepth+1), maxDepth, "expression is nested too deeply"},
+		{strings.Repeat("-", maxDepth+1) + "1", maxDepth, "expression is nested too deeply"},
+		{strings.Repeat("1", MaxLength+1), MaxLength, "expression exceeds 4096 characters"},
+	}
+	for _, tt := range tests {
+		_, err := Evaluate(tt.input)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Evaluate(%.20q): got %v, want a syntax error", tt.input, err)
+			continue
+		}
+		if syntaxErr.Pos != tt.pos || syntaxErr.Msg != tt.msg {
+			t.Errorf("Evaluate(%.20q): got %q at %d, want %q at %d", tt.input, syntaxErr.Msg, syntaxErr.Pos, tt.msg, tt.pos)
+		}
+	}
+
+	for _, input := range []string{
+		strings.Repeat("(", maxDepth) + "1" + strings.Repeat(")", maxDepth),
+		strings.Repeat("-", maxDepth) + "1",
+	} {
+		if _, err := Evaluate(input); err != nil {
+			t.Errorf("nesting to the limit: %v", err)
+		}
+	}
+}
+
+func TestRoundIsFinite(t *testing.T) {
+	for _, n := range []float64{-1e9, -309, -308, 0, 308, 309, 1e9} {
+		for _, x := range []float64{0, 1.5, -1.5, 1e-320, 1e308, -1e308} {
+			if got := functions["round"].call([]float64{x, n}); math.IsNaN(got) || math.IsInf(got, 0) {
+				t.Errorf("round(%v, %v) = %v", x, n, got)
+			}
+		}
+	}
+}
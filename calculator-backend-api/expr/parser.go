@@ -0,0 +1,211 @@
+package expr
+
+import "strconv"
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+// Parse parses an expression into a tree that can be evaluated with Eval.
+func Parse(input string) (Node, error) {
+	if len([]rune(input)) > MaxLength {
+		return nil, &SyntaxError{Pos: MaxLength, Msg: "expression exceeds " + strconv.Itoa(MaxLength) + " characters"}
+	}
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, &SyntaxError{Pos: 0, Msg: "empty expression"}
+	}
+	node, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, unexpected(tok)
+	}
+	return node, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) isOperator(ops ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokenOperator {
+		return false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+// enter notes a level of nesting opened by the token at pos.
+func (p *parser) enter(pos int) error {
+	p.depth++
+	if p.depth > maxDepth {
+		return &SyntaxError{Pos: pos, Msg: "expression is nested too deeply"}
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) expression() (Node, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("+", "-") {
+		op := p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op.text, pos: op.pos, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (Node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("*", "/") {
+		op := p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op.text, pos: op.pos, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (Node, error) {
+	if !p.isOperator("-", "+") {
+		return p.power()
+	}
+	if err := p.enter(p.peek().pos); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	op := p.next()
+	operand, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if op.text == "+" {
+		return operand, nil
+	}
+	return &negateNode{operand: operand}, nil
+}
+
+func (p *parser) power() (Node, error) {
+	base, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOperator("^") {
+		return base, nil
+	}
+	if err := p.enter(p.peek().pos); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	op := p.next()
+	exponent, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{op: op.text, pos: op.pos, left: base, right: exponent}, nil
+}
+
+func (p *parser) primary() (Node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		return numberNode(tok.num), nil
+	case tokenLParen:
+		if err := p.enter(tok.pos); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+
+		node, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, &SyntaxError{Pos: closing.pos, Msg: "expected \")\" to close \"(\" at position " + strconv.Itoa(tok.pos)}
+		}
+		return node, nil
+	case tokenIdent:
+		return p.call(tok)
+	}
+	return nil, unexpected(tok)
+}
+
+func (p *parser) call(name token) (Node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, &SyntaxError{Pos: name.pos, Msg: "unknown function " + strconv.Quote(name.text)}
+	}
+	open := p.next()
+	if open.kind != tokenLParen {
+		return nil, &SyntaxError{Pos: open.pos, Msg: "expected \"(\" after " + name.text}
+	}
+	if err := p.enter(open.pos); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	var args []Node
+	for {
+		arg, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.peek().kind != tokenComma {
+			break
+		}
+		p.next()
+	}
+	if closing := p.next(); closing.kind != tokenRParen {
+		return nil, &SyntaxError{Pos: closing.pos, Msg: "expected \")\" to close call to " + name.text}
+	}
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, &SyntaxError{Pos: name.pos, Msg: name.text + " expects " + fn.arity()}
+	}
+	return &callNode{name: name.text, fn: fn, args: args}, nil
+}
+
+func unexpected(tok token) *SyntaxError {
+	if tok.kind == tokenEOF {
+		return &SyntaxError{Pos: tok.pos, Msg: "unexpected end of expression"}
+	}
+	return &SyntaxError{Pos: tok.pos, Msg: "unexpected " + strconv.Quote(tok.text)}
+}
@@ -0,0 +1,194 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"path"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloudprojects/calculator-backend-api/handlers"
+	"golang.org/x/exp/slog"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDMetadata is the metadata key carrying the request ID, the gRPC
+// equivalent of the X-Request-ID header.
+const requestIDMetadata = "x-request-id"
+
+// requestIDInterceptor attaches the caller's request ID, or a new one, to the
+// context and returns it in the response header.
+func requestIDInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		id := firstMetadata(ctx, requestIDMetadata)
+		if !handlers.ValidRequestID(id) {
+			id = handlers.NewRequestID()
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, id))
+		return handler(handlers.ContextWithRequestID(ctx, id), req)
+	}
+}
+
+// recoveryInterceptor turns a panic in a call into an Internal error, like
+// handlers.RecoveryMiddleware.
+func recoveryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			id := handlers.RequestIDFromContext(ctx)
+			logger.Error("Recovered from panic",
+				"request_id", id,
+				"method", info.FullMethod,
+				"panic", rec,
+				"stack", string(debug.Stack()))
+			msg := fmt.Sprintf("%s, request ID %s", handlers.ErrInternal.Message, id)
+			resp, err = nil, withReason(codes.Internal, msg, handlers.CodeInternal, nil)
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// rateLimitInterceptor rejects clients that exceed the limiter's rate with
+// ResourceExhausted and a retry-after header, like
+// handlers.RateLimitMiddleware. Clients are identified by their identity, if
+// they were authenticated already, or by peer address.
+func rateLimitInterceptor(limiter *handlers.RateLimiter, logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		allowed, retryAfter := limiter.Allow(ctx, peerIP(ctx))
+		if !allowed {
+			grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))))
+			return nil, reject(ctx, logger, info, handlers.ErrRateLimited, caller(ctx))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// metricsInterceptor records the count, latency and error code of every
+// call, like handlers.MetricsMiddleware. The operation label is the method
+// name in lower case, and the status label the gRPC code.
+func metricsInterceptor(m *handlers.Metrics) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		finish := m.Start()
+		resp, err := handler(ctx, req)
+		finish(strings.ToLower(path.Base(info.FullMethod)), status.Code(err).String(), errorReason(err))
+		return resp, err
+	}
+}
+
+// errorReason returns the error code in err's ErrorInfo detail.
+func errorReason(err error) handlers.ErrorCode {
+	if err == nil {
+		return ""
+	}
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetDomain() == errorDomain {
+			return handlers.ErrorCode(info.GetReason())
+		}
+	}
+	return handlers.CodeInternal
+}
+
+// authInterceptor validates the bearer token in the authorization metadata,
+// like handlers.AuthMiddleware.
+func authInterceptor(auth *handlers.Authenticator, scope string, logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		scheme, token, ok := strings.Cut(firstMetadata(ctx, "authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return nil, reject(ctx, logger, info, handlers.ErrMissingToken, "")
+		}
+
+		id, err := auth.Authenticate(strings.TrimSpace(token))
+		if err != nil {
+			return nil, reject(ctx, logger, info, err, "")
+		}
+		if !id.Allows(scope) {
+			return nil, reject(ctx, logger, info, handlers.ErrForbidden, id.Subject)
+		}
+		return handler(handlers.ContextWithIdentity(ctx, id), req)
+	}
+}
+
+func reject(ctx context.Context, logger *slog.Logger, info *grpc.UnaryServerInfo, err error, caller string) error {
+	st := statusError(err)
+	logger.Warn("Call rejected",
+		"request_id", handlers.RequestIDFromContext(ctx),
+		"method", info.FullMethod,
+		"code", status.Code(st).String(),
+		"caller", caller)
+	return st
+}
+
+// loggingInterceptor logs every call, like handlers.LoggingMiddleware.
+func loggingInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		var caller string
+		if id, ok := handlers.IdentityFromContext(ctx); ok {
+			caller = id.Subject
+		}
+		level := slog.LevelInfo
+		if err != nil {
+			level = slog.LevelError
+		}
+		logger.Log(ctx, level, "Call completed",
+			"request_id", handlers.RequestIDFromContext(ctx),
+			"method", info.FullMethod,
+			"code", status.Code(err).String(),
+			"error", errorMessage(err),
+			"duration", time.Since(start),
+			"caller", caller,
+			"remote_ip", peerIP(ctx),
+			"user_agent", firstMetadata(ctx, "user-agent"))
+		return resp, err
+	}
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return status.Convert(err).Message()
+}
+
+// caller identifies who made the call: the authenticated subject when there
+// is one, otherwise the peer address.
+func caller(ctx context.Context) string {
+	if id, ok := handlers.IdentityFromContext(ctx); ok {
+		return id.Subject
+	}
+	return peerIP(ctx)
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+// Package grpcserver serves the calculator over gRPC, using the same
+// operations, rate limits, history and metrics as the HTTP handlers.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"cloudprojects/calculator-backend-api/calculatorpb"
+	"cloudprojects/calculator-backend-api/expr"
+	"cloudprojects/calculator-backend-api/handlers"
+	"cloudprojects/calculator-backend-api/history"
+	"golang.org/x/exp/slog"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the domain of the ErrorInfo details attached to errors.
+const errorDomain = "calculator"
+
+// Config holds what the gRPC server shares with the HTTP server. Each field
+// may be nil: without Auth calls are not authenticated, without a limiter
+// they are not rate limited, and without History or Metrics they are not
+// recorded.
+type Config struct {
+	Auth *handlers.Authenticator
+	// IPLimiter limits calls per peer address before they are
+	// authenticated, and Limiter per client after.
+	IPLimiter *handlers.RateLimiter
+	Limiter   *handlers.RateLimiter
+	History   *history.Store
+	Metrics   *handlers.Metrics
+}
+
+// New returns a gRPC server with the Calculator service registered.
+func New(cfg Config, logger *slog.Logger) *grpc.Server {
+	// Like the HTTP calculation routes, calls are rate limited per IP
+	// address, authenticated, then rate limited per client. Without
+	// authentication clients are identified by IP address anyway, so the
+	// first limit is left out.
+	interceptors := []grpc.UnaryServerInterceptor{requestIDInterceptor()}
+	if cfg.Metrics != nil {
+		interceptors = append(interceptors, metricsInterceptor(cfg.Metrics))
+	}
+	interceptors = append(interceptors, recoveryInterceptor(logger))
+	if cfg.Auth != nil {
+		if cfg.IPLimiter != nil {
+			interceptors = append(interceptors, rateLimitInterceptor(cfg.IPLimiter, logger))
+		}
+		interceptors = append(interceptors, authInterceptor(cfg.Auth, "calculate", logger))
+	}
+	if cfg.Limiter != nil {
+		interceptors = append(interceptors, rateLimitInterceptor(cfg.Limiter, logger))
+	}
+	interceptors = append(interceptors, loggingInterceptor(logger))
+
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	calculatorpb.RegisterCalculatorServer(srv, &calculatorServer{history: cfg.History, logger: logger})
+	return srv
+}
+
+type calculatorServer struct {
+	calculatorpb.UnimplementedCalculatorServer
+	history *history.Store
+	logger  *slog.Logger
+}
+
+func (s *calculatorServer) Add(ctx context.Context, req *calculatorpb.BinaryRequest) (*calculatorpb.Result, error) {
+	return s.binary(ctx, "add", req.GetNumber1(), req.GetNumber2())
+}
+
+func (s *calculatorServer) Subtract(ctx context.Context, req *calculatorpb.BinaryRequest) (*calculatorpb.Result, error) {
+	return s.binary(ctx, "subtract", req.GetNumber1(), req.GetNumber2())
+}
+
+func (s *calculatorServer) Multiply(ctx context.Context, req *calculatorpb.BinaryRequest) (*calculatorpb.Result, error) {
+	return s.binary(ctx, "multiply", req.GetNumber1(), req.GetNumber2())
+}
+
+func (s *calculatorServer) Divide(ctx context.Context, req *calculatorpb.DivideRequest) (*calculatorpb.Result, error) {
+	return s.binary(ctx, "divide", req.GetDividend(), req.GetDivisor())
+}
+
+func (s *calculatorServer) Sum(ctx context.Context, req *calculatorpb.SumRequest) (*calculatorpb.Result, error) {
+	numbers := req.GetNumbers()
+	operands := make([]any, len(numbers))
+	for i, n := range numbers {
+		operands[i] = n
+	}
+	value, err := handlers.Sum(numbers)
+	return s.result(ctx, "sum", operands, value, err)
+}
+
+func (s *calculatorServer) Evaluate(ctx context.Context, req *calculatorpb.EvaluateRequest) (*calculatorpb.Result, error) {
+	value, err := handlers.Evaluate(req.GetExpression())
+	return s.result(ctx, "evaluate", []any{req.GetExpression()}, value, err)
+}
+
+func (s *calculatorServer) binary(ctx context.Context, operation string, a, b float64) (*calculatorpb.Result, error) {
+	value, err := handlers.Calculate(operation, a, b)
+	return s.result(ctx, operation, []any{a, b}, value, err)
+}
+
+// result records the calculation in the history and returns its outcome.
+func (s *calculatorServer) result(ctx context.Context, operation string, operands []any, value float64, err error) (*calculatorpb.Result, error) {
+	if s.history != nil {
+		entry := handlers.NewHistoryEntry(operation, operands, value, err)
+		entry.Timestamp = time.Now().UTC()
+		entry.RequestID = handlers.RequestIDFromContext(ctx)
+		entry.Caller = caller(ctx)
+		if _, err := s.history.Append(entry); err != nil {
+			s.logger.Error("Failed to record calculation",
+				"request_id", entry.RequestID,
+				"operation", operation,
+				"error", err)
+		}
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &calculatorpb.Result{Result: value}, nil
+}
+
+// statusError converts an operation error into a gRPC status carrying the
+// HTTP API's error code as an ErrorInfo reason.
+func statusError(err error) error {
+	var syntaxErr *expr.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return withReason(codes.InvalidArgument, syntaxErr.Msg, handlers.CodeSyntaxError, map[string]string{
+			"position": strconv.Itoa(syntaxErr.Pos),
+		})
+	}
+
+	var opErr *handlers.OperationError
+	if !errors.As(err, &opErr) {
+		return status.Error(codes.Internal, "internal server error")
+	}
+	return withReason(grpcCode(opErr.Code), opErr.Message, opErr.Code, nil)
+}
+
+func grpcCode(code handlers.ErrorCode) codes.Code {
+	switch code {
+	case handlers.CodeOverflow, handlers.CodeOutOfRange:
+		return codes.OutOfRange
+	case handlers.CodeUnauthorized:
+		return codes.Unauthenticated
+	case handlers.CodeForbidden:
+		return codes.PermissionDenied
+	case handlers.CodeRateLimited:
+		return codes.ResourceExhausted
+	case handlers.CodeInternal:
+		return codes.Internal
+	}
+	return codes.InvalidArgument
+}
+
+func withReason(code codes.Code, msg string, reason handlers.ErrorCode, metadata map[string]string) error {
+	st := status.New(code, msg)
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: string(reason), Domain: errorDomain, Metadata: metadata})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,254 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloudprojects/calculator-backend-api/calculatorpb"
+	"cloudprojects/calculator-backend-api/handlers"
+	"cloudprojects/calculator-backend-api/history"
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testAPIKey is accepted by servers started with testAuth.
+const testAPIKey = "test-key"
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func testAuth(t *testing.T) *handlers.Authenticator {
+	t.Helper()
+	auth, err := handlers.NewAuthenticator(handlers.AuthConfig{
+		APIKeys: []handlers.APIKey{{Name: "test", Key: testAPIKey}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+func testLimiter(t *testing.T, burst int) *handlers.RateLimiter {
+	t.Helper()
+	limiter, err := handlers.NewRateLimiter(handlers.RateLimitConfig{Rate: 0.001, Burst: burst})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return limiter
+}
+
+// newTestClient serves the calculator with cfg over an in-memory connection
+// and returns a client for it.
+func newTestClient(t *testing.T, cfg Config) calculatorpb.CalculatorClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := New(cfg, discard)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return calculatorpb.NewCalculatorClient(conn)
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestCalculator(t *testing.T) {
+	client := newTestClient(t, Config{})
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		call   func() (*calculatorpb.Result, error)
+		want   float64
+		code   codes.Code
+		reason handlers.ErrorCode
+	}{
+		{"add", func() (*calculatorpb.Result, error) {
+			return client.Add(ctx, &calculatorpb.BinaryRequest{Number1: 1, Number2: 2})
+		}, 3, codes.OK, ""},
+		{"subtract", func() (*calculatorpb.Result, error) {
+			return client.Subtract(ctx, &calculatorpb.BinaryRequest{Number1: 1, Number2: 2})
+		}, -1, codes.OK, ""},
+		{"multiply", func() (*calculatorpb.Result, error) {
+			return client.Multiply(ctx, &calculatorpb.BinaryRequest{Number1: 3, Number2: 4})
+		}, 12, codes.OK, ""},
+		{"divide", func() (*calculatorpb.Result, error) {
+			return client.Divide(ctx, &calculatorpb.DivideRequest{Dividend: 1, Divisor: 4})
+		}, 0.25, codes.OK, ""},
+		{"divide by zero", func() (*calculatorpb.Result, error) {
+			return client.Divide(ctx, &calculatorpb.DivideRequest{Dividend: 1, Divisor: 0})
+		}, 0, codes.InvalidArgument, handlers.CodeDivisionByZero},
+		{"sum", func() (*calculatorpb.Result, error) {
+			return client.Sum(ctx, &calculatorpb.SumRequest{Numbers: []float64{1, 2, 3}})
+		}, 6, codes.OK, ""},
+		{"evaluate", func() (*calculatorpb.Result, error) {
+			return client.Evaluate(ctx, &calculatorpb.EvaluateRequest{Expression: "2 * (3 + 4)"})
+		}, 14, codes.OK, ""},
+		{"syntax error", func() (*calculatorpb.Result, error) {
+			return client.Evaluate(ctx, &calculatorpb.EvaluateRequest{Expression: "2 *"})
+		}, 0, codes.InvalidArgument, handlers.CodeSyntaxError},
+		{"overflow", func() (*calculatorpb.Result, error) {
+			return client.Multiply(ctx, &calculatorpb.BinaryRequest{Number1: 1e308, Number2: 10})
+		}, 0, codes.OutOfRange, handlers.CodeOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.call()
+			if status.Code(err) != tt.code || errorReason(err) != tt.reason {
+				t.Fatalf("got %v (reason %q), want %s (reason %q)", err, errorReason(err), tt.code, tt.reason)
+			}
+			if err == nil && res.GetResult() != tt.want {
+				t.Errorf("got %v, want %v", res.GetResult(), tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	client := newTestClient(t, Config{Auth: testAuth(t)})
+	req := &calculatorpb.BinaryRequest{Number1: 1, Number2: 2}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{"no token", context.Background(), codes.Unauthenticated},
+		{"unknown token", withToken("wrong"), codes.Unauthenticated},
+		{"valid token", withToken(testAPIKey), codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.Add(tt.ctx, req); status.Code(err) != tt.code {
+				t.Errorf("got %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		token string
+		want  []codes.Code
+	}{
+		{
+			name:  "per client",
+			cfg:   Config{Auth: testAuth(t), Limiter: testLimiter(t, 2)},
+			token: testAPIKey,
+			want:  []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted},
+		},
+		{
+			name:  "per IP address before authentication",
+			cfg:   Config{Auth: testAuth(t), IPLimiter: testLimiter(t, 2)},
+			token: "guess",
+			want:  []codes.Code{codes.Unauthenticated, codes.Unauthenticated, codes.ResourceExhausted},
+		},
+		{
+			name: "without authentication",
+			cfg:  Config{Limiter: testLimiter(t, 1)},
+			want: []codes.Code{codes.OK, codes.ResourceExhausted},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, tt.cfg)
+			for i, want := range tt.want {
+				var header metadata.MD
+				_, err := client.Add(withToken(tt.token), &calculatorpb.BinaryRequest{Number1: 1, Number2: 2}, grpc.Header(&header))
+				if status.Code(err) != want {
+					t.Fatalf("call %d: got %v, want %s", i+1, err, want)
+				}
+				if want == codes.ResourceExhausted {
+					if errorReason(err) != handlers.CodeRateLimited {
+						t.Errorf("call %d: got reason %q, want %q", i+1, errorReason(err), handlers.CodeRateLimited)
+					}
+					if len(header.Get("retry-after")) == 0 {
+						t.Errorf("call %d: retry-after header is missing", i+1)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRecoveryInterceptor(t *testing.T) {
+	intercept := recoveryInterceptor(discard)
+	info := &grpc.UnaryServerInfo{FullMethod: "/calculator.v1.Calculator/Add"}
+	ctx := handlers.ContextWithRequestID(context.Background(), "req-1")
+
+	_, err := intercept(ctx, nil, info, func(context.Context, any) (any, error) {
+		panic("boom")
+	})
+	if status.Code(err) != codes.Internal || errorReason(err) != handlers.CodeInternal {
+		t.Fatalf("got %v, want Internal", err)
+	}
+	if !strings.Contains(status.Convert(err).Message(), "req-1") {
+		t.Errorf("message %q does not mention the request ID", status.Convert(err).Message())
+	}
+}
+
+func TestHistoryAndMetrics(t *testing.T) {
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.jsonl"), history.Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	metrics := handlers.NewMetrics()
+	client := newTestClient(t, Config{Auth: testAuth(t), History: store, Metrics: metrics})
+
+	ctx := withToken(testAPIKey)
+	if _, err := client.Add(ctx, &calculatorpb.BinaryRequest{Number1: 1, Number2: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Divide(ctx, &calculatorpb.DivideRequest{Dividend: 1, Divisor: 0}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	entries, total, err := store.Query(history.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 {
+		t.Fatalf("got %d history entries, want 2", total)
+	}
+	for i, want := range []history.Entry{
+		{Operation: "divide", Error: string(handlers.CodeDivisionByZero), Caller: "test"},
+		{Operation: "add", Result: 3.0, Caller: "test"},
+	} {
+		got := entries[i]
+		if got.Operation != want.Operation || got.Error != want.Error || got.Result != want.Result || got.Caller != want.Caller || got.RequestID == "" {
+			t.Errorf("entry %d: got %+v, want %+v with a request ID", i, got, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, want := range []string{
+		`calculator_requests_total{operation="add",status="OK"} 1`,
+		`calculator_requests_total{operation="divide",status="InvalidArgument"} 1`,
+		`calculator_errors_total{code="division_by_zero",operation="divide"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+}
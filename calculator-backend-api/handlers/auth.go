@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// jwtLeeway is the clock skew tolerated when checking exp and nbf claims.
+const jwtLeeway = 30 * time.Second
+
+// AuthConfig is the authentication config file. API keys are matched
+// exactly; JWTs must be HS256-signed with the shared secret.
+type AuthConfig struct {
+	APIKeys []APIKey  `json:"api_keys"`
+	JWT     JWTConfig `json:"jwt"`
+}
+
+// APIKey is a static bearer token. A key without scopes may access every
+// protected route.
+type APIKey struct {
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Scopes []string `json:"scopes"`
+}
+
+// JWTConfig enables HMAC-signed JWTs when Secret is set. Issuer and Audience
+// are checked only when set.
+type JWTConfig struct {
+	Secret   string `json:"secret"`
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+}
+
+// LoadAuthConfig reads an AuthConfig from a JSON file.
+func LoadAuthConfig(path string) (AuthConfig, error) {
+	var cfg AuthConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	Subject string
+	// Method is "api_key" or "jwt".
+	Method string
+	Scopes []string
+}
+
+// Allows reports whether the identity may access a route requiring scope.
+func (id Identity) Allows(scope string) bool {
+	return len(id.Scopes) == 0 || slices.Contains(id.Scopes, scope)
+}
+
+type identityKey struct{}
+
+// ContextWithIdentity returns a copy of ctx carrying the caller's identity.
+func ContextWithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the caller attached by AuthMiddleware.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// Authenticator validates bearer tokens against an AuthConfig.
+type Authenticator struct {
+	keys map[[sha256.Size]byte]APIKey
+	jwt  JWTConfig
+}
+
+func NewAuthenticator(cfg AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{keys: make(map[[sha256.Size]byte]APIKey), jwt: cfg.JWT}
+	for i, key := range cfg.APIKeys {
+		if key.Key == "" || key.Name == "" {
+			return nil, fmt.Errorf("api key %d: name and key are required", i)
+		}
+		// Keys are looked up by digest so that lookups do not compare the
+		// secret byte by byte.
+		a.keys[sha256.Sum256([]byte(key.Key))] = key
+	}
+	if len(a.keys) == 0 && a.jwt.Secret == "" {
+		return nil, errors.New("no api keys or jwt secret configured")
+	}
+	return a, nil
+}
+
+// Authenticate resolves a bearer token to the caller's identity.
+func (a *Authenticator) Authenticate(token string) (Identity, error) {
+	if key, ok := a.keys[sha256.Sum256([]byte(token))]; ok {
+		return Identity{Subject: key.Name, Method: "api_key", Scopes: key.Scopes}, nil
+	}
+	if a.jwt.Secret != "" && strings.Count(token, ".") == 2 {
+		return a.verifyJWT(token, time.Now())
+	}
+	return Identity{}, ErrInvalidToken
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Scope     string          `json:"scope"`
+}
+
+// hasAudience reports whether the aud claim, a string or an array of
+// strings, contains audience.
+func (c jwtClaims) hasAudience(audience string) bool {
+	var single string
+	if json.Unmarshal(c.Audience, &single) == nil {
+		return single == audience
+	}
+	var many []string
+	return json.Unmarshal(c.Audience, &many) == nil && slices.Contains(many, audience)
+}
+
+func (a *Authenticator) verifyJWT(token string, now time.Time) (Identity, error) {
+	parts := strings.Split(token, ".")
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return Identity{}, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, []byte(a.jwt.Secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return Identity{}, ErrInvalidToken
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil || claims.Subject == "" {
+		return Identity{}, ErrInvalidToken
+	}
+	if claims.ExpiresAt != nil && now.After(time.Unix(*claims.ExpiresAt, 0).Add(jwtLeeway)) {
+		return Identity{}, ErrExpiredToken
+	}
+	if claims.NotBefore != nil && now.Before(time.Unix(*claims.NotBefore, 0).Add(-jwtLeeway)) {
+		return Identity{}, ErrInvalidToken
+	}
+	if a.jwt.Issuer != "" && claims.Issuer != a.jwt.Issuer {
+		return Identity{}, ErrInvalidToken
+	}
+	if a.jwt.Audience != "" && !claims.hasAudience(a.jwt.Audience) {
+		return Identity{}, ErrInvalidToken
+	}
+	return Identity{Subject: claims.Subject, Method: "jwt", Scopes: strings.Fields(claims.Scope)}, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// AuthMiddleware rejects requests without a valid bearer token granting
+// scope, and attaches the caller's Identity to the request context.
+func AuthMiddleware(next http.Handler, auth *Authenticator, scope string, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			rejectRequest(logger, w, r, ErrMissingToken, "")
+			return
+		}
+
+		id, err := auth.Authenticate(token)
+		if err != nil {
+			rejectRequest(logger, w, r, err, "")
+			return
+		}
+		if !id.Allows(scope) {
+			rejectRequest(logger, w, r, ErrForbidden, id.Subject)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ContextWithIdentity(r.Context(), id)))
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func rejectRequest(logger *slog.Logger, w http.ResponseWriter, r *http.Request, err error, caller string) {
+	opErr := asOperationError(err)
+	setLogError(r, opErr.Code)
+	requestLogger(logger, r).Warn("Request rejected",
+		"path", r.URL.Path,
+		"method", r.Method,
+		"status", opErr.Status,
+		"code", opErr.Code,
+		"caller", caller)
+	switch {
+	case err == ErrMissingToken:
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	case opErr.Status == http.StatusUnauthorized:
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+	writeResponse(logger, w, opErr.Status, opErr.response())
+}
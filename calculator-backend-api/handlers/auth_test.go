@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+const testSecret = "test-secret"
+
+// signJWT returns an HS256 JWT for claims, signed with secret.
+func signJWT(t *testing.T, secret string, claims map[string]any) string {
+	t.Helper()
+	segment := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := segment(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + segment(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func testAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	auth, err := NewAuthenticator(AuthConfig{
+		APIKeys: []APIKey{
+			{Name: "admin", Key: "admin-key"},
+			{Name: "reader", Key: "reader-key", Scopes: []string{"history:read"}},
+		},
+		JWT: JWTConfig{Secret: testSecret, Issuer: "https://issuer.example", Audience: "calculator"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+func TestNewAuthenticatorValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  AuthConfig
+	}{
+		{"nothing configured", AuthConfig{}},
+		{"key without name", AuthConfig{APIKeys: []APIKey{{Key: "k"}}}},
+		{"name without key", AuthConfig{APIKeys: []APIKey{{Name: "n"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAuthenticator(tt.cfg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	auth := testAuthenticator(t)
+
+	tests := []struct {
+		token   string
+		subject string
+		scopes  []string
+		err     error
+	}{
+		{"admin-key", "admin", nil, nil},
+		{"reader-key", "reader", []string{"history:read"}, nil},
+		{"admin-key ", "", nil, ErrInvalidToken},
+		{"unknown", "", nil, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			id, err := auth.Authenticate(tt.token)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if id.Subject != tt.subject || !slices.Equal(id.Scopes, tt.scopes) {
+				t.Errorf("got %+v, want subject %q with scopes %v", id, tt.subject, tt.scopes)
+			}
+			if err == nil && id.Method != "api_key" {
+				t.Errorf("got method %q, want api_key", id.Method)
+			}
+		})
+	}
+}
+
+func TestVerifyJWT(t *testing.T) {
+	auth := testAuthenticator(t)
+	now := time.Unix(1_700_000_000, 0)
+	unix := func(d time.Duration) int64 { return now.Add(d).Unix() }
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"sub":   "alice",
+			"iss":   "https://issuer.example",
+			"aud":   "calculator",
+			"exp":   unix(time.Hour),
+			"scope": "calculate history:read",
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	valid := signJWT(t, testSecret, claims(nil))
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"valid", valid, nil},
+		{"without exp", signJWT(t, testSecret, claims(map[string]any{"exp": nil})), nil},
+		{"expired", signJWT(t, testSecret, claims(map[string]any{"exp": unix(-time.Minute)})), ErrExpiredToken},
+		{"expired within leeway", signJWT(t, testSecret, claims(map[string]any{"exp": unix(-jwtLeeway + time.Second)})), nil},
+		{"not yet valid", signJWT(t, testSecret, claims(map[string]any{"nbf": unix(time.Minute)})), ErrInvalidToken},
+		{"not yet valid within leeway", signJWT(t, testSecret, claims(map[string]any{"nbf": unix(jwtLeeway - time.Second)})), nil},
+		{"wrong issuer", signJWT(t, testSecret, claims(map[string]any{"iss": "https://evil.example"})), ErrInvalidToken},
+		{"missing issuer", signJWT(t, testSecret, claims(map[string]any{"iss": nil})), ErrInvalidToken},
+		{"wrong audience", signJWT(t, testSecret, claims(map[string]any{"aud": "other"})), ErrInvalidToken},
+		{"audience list", signJWT(t, testSecret, claims(map[string]any{"aud": []string{"other", "calculator"}})), nil},
+		{"audience list without ours", signJWT(t, testSecret, claims(map[string]any{"aud": []string{"other"}})), ErrInvalidToken},
+		{"missing audience", signJWT(t, testSecret, claims(map[string]any{"aud": nil})), ErrInvalidToken},
+		{"missing subject", signJWT(t, testSecret, claims(map[string]any{"sub": nil})), ErrInvalidToken},
+		{"wrong secret", signJWT(t, "other-secret", claims(nil)), ErrInvalidToken},
+		{"tampered claims", valid[:40] + "x" + valid[41:], ErrInvalidToken},
+		{"unsigned", "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"alice"}`)) + ".", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := auth.verifyJWT(tt.token, now)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err == nil && (id.Subject != "alice" || id.Method != "jwt" || !slices.Equal(id.Scopes, []string{"calculate", "history:read"})) {
+				t.Errorf("got %+v, want alice's identity", id)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	auth := testAuthenticator(t)
+	var got Identity
+	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = IdentityFromContext(r.Context())
+	}), auth, "calculate", discard)
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		challenge     string
+	}{
+		{"api key", "Bearer admin-key", http.StatusOK, ""},
+		{"scheme is case insensitive", "bearer admin-key", http.StatusOK, ""},
+		{"missing", "", http.StatusUnauthorized, "Bearer"},
+		{"other scheme", "Basic YWRtaW46YWRtaW4=", http.StatusUnauthorized, "Bearer"},
+		{"invalid", "Bearer unknown", http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"scope not granted", "Bearer reader-key", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = Identity{}
+			r := httptest.NewRequest("POST", "/add", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+			if rec.Code != tt.status {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.status)
+			}
+			if challenge := rec.Header().Get("WWW-Authenticate"); challenge != tt.challenge {
+				t.Errorf("got WWW-Authenticate %q, want %q", challenge, tt.challenge)
+			}
+			if tt.status == http.StatusOK && got.Subject != "admin" {
+				t.Errorf("got identity %+v in the request context, want admin", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/exp/slog"
+)
+
+// BatchItem is a single operation in a /batch request. Op is one of add,
+// subtract, multiply, divide or a two-operand operation such as power.
+type BatchItem struct {
+	Op string  `json:"op"`
+	A  float64 `json:"a"`
+	B  float64 `json:"b"`
+}
+
+// BatchRequest is the body accepted by /batch, a bare array of items.
+type BatchRequest []BatchItem
+
+// BatchResponse holds one result or error per item, in request order.
+type BatchResponse struct {
+	Results []Response `json:"results"`
+}
+
+// BatchHandler evaluates up to maxItems operations in one request. A failing
+// item does not fail the batch; its error is reported in its own result.
+func BatchHandler(logger *slog.Logger, maxItems int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(logger, r).With("operation", "batch")
+		setLogOperation(r, "batch")
+
+		var req BatchRequest
+		if err := decodeRequest(r, &req); err != nil {
+			handleDecodeError(logger, w, r, err)
+			return
+		}
+		if len(req) > maxItems {
+			setLogError(r, CodeBatchTooLarge)
+			logger.Error("Batch too large", "items", len(req), "max", maxItems)
+			writeResponse(logger, w, ErrBatchTooLarge.Status, Response{
+				Error:   string(CodeBatchTooLarge),
+				Message: fmt.Sprintf("batch has %d items, the maximum is %d", len(req), maxItems),
+			})
+			return
+		}
+
+		resp := BatchResponse{Results: make([]Response, len(req))}
+		operands := make([]any, len(req))
+		failed := 0
+		for i, item := range req {
+			operands[i] = item
+			result, err := Calculate(item.Op, item.A, item.B)
+			if err != nil {
+				resp.Results[i] = asOperationError(err).response()
+				failed++
+				continue
+			}
+			resp.Results[i] = Response{Result: result}
+		}
+
+		recordCall(r, "batch", operands, resp.Results, nil)
+		logger.Debug("Batch evaluated", "items", len(req), "failed", failed)
+		writeJSON(logger, w, http.StatusOK, resp)
+	}
+}
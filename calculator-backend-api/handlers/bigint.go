@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// Limits on big integer operations, which keep the CPU time and memory a
+// single request can use small.
+const (
+	// MaxBigIntDigits is the most decimal digits an operand may have.
+	MaxBigIntDigits = 1000
+	// MaxFactorial is the largest n accepted by /bigint/factorial.
+	MaxFactorial = 10000
+	// MaxBigIntOperands is the most integers /bigint/gcd and /bigint/lcm
+	// accept.
+	MaxBigIntOperands = 100
+	// MaxBigIntResultDigits is the most decimal digits a result may have.
+	// It leaves room for the factorial of MaxFactorial, which has 35660.
+	MaxBigIntResultDigits = 40000
+)
+
+// bigIntResultLimit is the smallest integer with more than
+// MaxBigIntResultDigits digits.
+var bigIntResultLimit = new(big.Int).Exp(big.NewInt(10), big.NewInt(MaxBigIntResultDigits), nil)
+
+// primalityRounds is the number of Miller-Rabin rounds run by
+// /bigint/isprime, on top of the Baillie-PSW test.
+const primalityRounds = 20
+
+// BigIntRequest is the body accepted by /bigint/factorial and
+// /bigint/isprime. The operands of big integer operations may be JSON
+// numbers or strings of decimal digits, which stay exact in clients that
+// parse JSON numbers as floats. Results are strings for the same reason.
+type BigIntRequest struct {
+	N json.Number `json:"n"`
+}
+
+// BigIntListRequest is the body accepted by /bigint/gcd and /bigint/lcm.
+type BigIntListRequest struct {
+	Numbers []json.Number `json:"numbers"`
+}
+
+// ModPowRequest is the body accepted by /bigint/modpow.
+type ModPowRequest struct {
+	Base     json.Number `json:"base"`
+	Exponent json.Number `json:"exponent"`
+	Modulus  json.Number `json:"modulus"`
+}
+
+// BigIntResponse holds the result of a big integer operation as a string of
+// decimal digits.
+type BigIntResponse struct {
+	Result string `json:"result"`
+}
+
+// PrimeResponse is returned by /bigint/isprime.
+type PrimeResponse struct {
+	Result bool `json:"result"`
+}
+
+// bigIntOperationSpec declares an operation on big integers. request and
+// response are values of its body types, and the request type decides how
+// operands are read.
+type bigIntOperationSpec struct {
+	name     string
+	summary  string
+	request  any
+	response any
+	apply    func(x []*big.Int) (any, error)
+}
+
+// bigIntOperations is the registry of big integer operations, each served at
+// /bigint/<name>.
+var bigIntOperations = []bigIntOperationSpec{
+	{name: "factorial", summary: "Factorial of n",
+		request: BigIntRequest{}, response: BigIntResponse{}, apply: factorial},
+	{name: "gcd", summary: "Greatest common divisor of integers",
+		request: BigIntListRequest{}, response: BigIntResponse{}, apply: gcd},
+	{name: "lcm", summary: "Least common multiple of integers",
+		request: BigIntListRequest{}, response: BigIntResponse{}, apply: lcm},
+	{name: "modpow", summary: "base raised to exponent, modulo modulus",
+		request: ModPowRequest{}, response: BigIntResponse{}, apply: modPow},
+	{name: "isprime", summary: "Whether n is prime",
+		request: BigIntRequest{}, response: PrimeResponse{}, apply: isPrime},
+}
+
+// lookupBigIntOperation finds a big integer operation by name.
+func lookupBigIntOperation(name string) (*bigIntOperationSpec, bool) {
+	i := slices.IndexFunc(bigIntOperations, func(spec bigIntOperationSpec) bool { return spec.name == name })
+	if i < 0 {
+		return nil, false
+	}
+	return &bigIntOperations[i], true
+}
+
+// BigIntOperationNames returns the names of the big integer operations, in
+// registration order.
+func BigIntOperationNames() []string {
+	names := make([]string, len(bigIntOperations))
+	for i, spec := range bigIntOperations {
+		names[i] = spec.name
+	}
+	return names
+}
+
+func factorial(x []*big.Int) (any, error) {
+	n := x[0]
+	switch {
+	case n.Sign() < 0:
+		return nil, domainError(FieldError{Field: "n", Reason: "must not be negative"})
+	case n.Cmp(big.NewInt(MaxFactorial)) > 0:
+		return nil, bigIntOutOfRange("n", fmt.Sprintf("must be at most %d", MaxFactorial))
+	}
+	result := new(big.Int).MulRange(1, n.Int64())
+	if resultTooLarge(result) {
+		return nil, resultOutOfRange("n")
+	}
+	return BigIntResponse{Result: result.String()}, nil
+}
+
+func gcd(x []*big.Int) (any, error) {
+	result := new(big.Int)
+	for _, n := range x {
+		result.GCD(nil, nil, result, n)
+	}
+	return BigIntResponse{Result: result.String()}, nil
+}
+
+func lcm(x []*big.Int) (any, error) {
+	result := big.NewInt(1)
+	for _, n := range x {
+		if n.Sign() == 0 {
+			return BigIntResponse{Result: "0"}, nil
+		}
+		d := new(big.Int).GCD(nil, nil, result, n)
+		result.Mul(result, new(big.Int).Abs(n))
+		result.Quo(result, d)
+		// Checked as it grows, since the operands can multiply up to far
+		// more digits than any of them has.
+		if resultTooLarge(result) {
+			return nil, resultOutOfRange("numbers")
+		}
+	}
+	return BigIntResponse{Result: result.String()}, nil
+}
+
+func modPow(x []*big.Int) (any, error) {
+	base, exponent, modulus := x[0], x[1], x[2]
+	var fields []FieldError
+	if exponent.Sign() < 0 {
+		fields = append(fields, FieldError{Field: "exponent", Reason: "must not be negative"})
+	}
+	if modulus.Sign() <= 0 {
+		fields = append(fields, FieldError{Field: "modulus", Reason: "must be greater than 0"})
+	}
+	if len(fields) > 0 {
+		return nil, domainError(fields...)
+	}
+	result := new(big.Int).Exp(base, exponent, modulus)
+	return BigIntResponse{Result: result.Mod(result, modulus).String()}, nil
+}
+
+func isPrime(x []*big.Int) (any, error) {
+	return PrimeResponse{Result: x[0].ProbablyPrime(primalityRounds)}, nil
+}
+
+func bigIntOutOfRange(field, reason string) *OperationError {
+	return &OperationError{
+		Code:    CodeOutOfRange,
+		Status:  http.StatusBadRequest,
+		Message: ErrOutOfRange.Message,
+		Fields:  []FieldError{{Field: field, Reason: reason}},
+	}
+}
+
+// resultTooLarge reports whether x has more than MaxBigIntResultDigits
+// digits. Comparing bit lengths first settles most cases without looking at
+// the digits.
+func resultTooLarge(x *big.Int) bool {
+	return x.BitLen() >= bigIntResultLimit.BitLen() && x.CmpAbs(bigIntResultLimit) >= 0
+}
+
+func resultOutOfRange(field string) *OperationError {
+	return bigIntOutOfRange(field, fmt.Sprintf("result would have more than %d digits", MaxBigIntResultDigits))
+}
+
+// parseBigInt parses the operand in field, rejecting operands with more than
+// MaxBigIntDigits digits before doing any work on them.
+func parseBigInt(field string, n json.Number) (*big.Int, error) {
+	s := n.String()
+	if len(strings.TrimLeft(s, "+-")) > MaxBigIntDigits {
+		return nil, bigIntOutOfRange(field, fmt.Sprintf("must have at most %d digits", MaxBigIntDigits))
+	}
+	x, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, invalidPayload(FieldError{Field: field, Reason: "must be an integer"})
+	}
+	return x, nil
+}
+
+// bigIntOperands returns the operands of a decoded request body and their
+// field names.
+func bigIntOperands(req any) ([]json.Number, []string) {
+	switch req := req.(type) {
+	case *BigIntRequest:
+		return []json.Number{req.N}, []string{"n"}
+	case *BigIntListRequest:
+		names := make([]string, len(req.Numbers))
+		for i := range names {
+			names[i] = fmt.Sprintf("numbers[%d]", i)
+		}
+		return req.Numbers, names
+	case *ModPowRequest:
+		return []json.Number{req.Base, req.Exponent, req.Modulus}, []string{"base", "exponent", "modulus"}
+	}
+	panic(fmt.Sprintf("handlers: unsupported big integer request %T", req))
+}
+
+// run parses the operands, checks their number and applies the operation.
+func (spec *bigIntOperationSpec) run(numbers []json.Number, names []string) (any, error) {
+	if _, isList := spec.request.(BigIntListRequest); isList {
+		switch {
+		case len(numbers) < 2:
+			return nil, invalidPayload(FieldError{Field: "numbers", Reason: "must contain at least 2 integers"})
+		case len(numbers) > MaxBigIntOperands:
+			return nil, invalidPayload(FieldError{Field: "numbers", Reason: fmt.Sprintf("must contain at most %d integers", MaxBigIntOperands)})
+		}
+	}
+	x := make([]*big.Int, len(numbers))
+	for i, n := range numbers {
+		v, err := parseBigInt(names[i], n)
+		if err != nil {
+			return nil, err
+		}
+		x[i] = v
+	}
+	return spec.apply(x)
+}
+
+// BigIntHandler serves the big integer operation named op. It panics if op is
+// not registered. Results are not cached, since they can be large.
+func BigIntHandler(logger *slog.Logger, op string) http.HandlerFunc {
+	spec, ok := lookupBigIntOperation(op)
+	if !ok {
+		panic("handlers: unknown big integer operation " + op)
+	}
+	name := "bigint/" + spec.name
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(logger, r).With("operation", name)
+		setLogOperation(r, name)
+
+		req := reflect.New(reflect.TypeOf(spec.request)).Interface()
+		if err := decodeRequest(r, req); err != nil {
+			handleDecodeError(logger, w, r, err)
+			return
+		}
+
+		numbers, names := bigIntOperands(req)
+		recorded := make([]any, len(numbers))
+		for i, n := range numbers {
+			recorded[i] = n.String()
+		}
+		resp, err := spec.run(numbers, names)
+		var result any
+		switch resp := resp.(type) {
+		case BigIntResponse:
+			result = resp.Result
+		case PrimeResponse:
+			result = resp.Result
+		}
+		recordCall(r, name, recorded, result, err)
+		if err != nil {
+			writeError(logger, w, err)
+			return
+		}
+		writeJSON(logger, w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// primePowers returns the largest powers of the first count primes that have
+// at most MaxBigIntDigits digits. Their least common multiple is their
+// product.
+func primePowers(count int) []json.Number {
+	var numbers []json.Number
+	for p := int64(2); len(numbers) < count; p++ {
+		if !big.NewInt(p).ProbablyPrime(0) {
+			continue
+		}
+		k := int64(float64(MaxBigIntDigits) / math.Log10(float64(p)))
+		power := new(big.Int).Exp(big.NewInt(p), big.NewInt(k), nil)
+		for len(power.String()) > MaxBigIntDigits {
+			power.Quo(power, big.NewInt(p))
+		}
+		numbers = append(numbers, json.Number(power.String()))
+	}
+	return numbers
+}
+
+func TestBigIntOperations(t *testing.T) {
+	tests := []struct {
+		op      string
+		numbers []json.Number
+		want    string
+		digits  int
+		code    ErrorCode
+	}{
+		{op: "factorial", numbers: []json.Number{"0"}, want: "1"},
+		{op: "factorial", numbers: []json.Number{"20"}, want: "2432902008176640000"},
+		{op: "factorial", numbers: []json.Number{"10000"}, digits: 35660},
+		{op: "factorial", numbers: []json.Number{"10001"}, code: CodeOutOfRange},
+		{op: "factorial", numbers: []json.Number{"-1"}, code: CodeDomainError},
+		{op: "gcd", numbers: []json.Number{"12", "18", "-30"}, want: "6"},
+		{op: "gcd", numbers: []json.Number{"0", "0"}, want: "0"},
+		{op: "lcm", numbers: []json.Number{"12", "18", "-30"}, want: "180"},
+		{op: "lcm", numbers: []json.Number{"12", "0"}, want: "0"},
+		{op: "lcm", numbers: primePowers(30), digits: 29976},
+		{op: "lcm", numbers: primePowers(MaxBigIntOperands), code: CodeOutOfRange},
+		{op: "lcm", numbers: []json.Number{"1"}, code: CodeInvalidPayload},
+		{op: "modpow", numbers: []json.Number{"4", "13", "497"}, want: "445"},
+		{op: "modpow", numbers: []json.Number{"-4", "1", "7"}, want: "3"},
+		{op: "modpow", numbers: []json.Number{"4", "-1", "0"}, code: CodeDomainError},
+		{op: "isprime", numbers: []json.Number{"170141183460469231731687303715884105727"}, want: "true"},
+		{op: "isprime", numbers: []json.Number{"1"}, want: "false"},
+		{op: "gcd", numbers: []json.Number{"1.5", "2"}, code: CodeInvalidPayload},
+		{op: "gcd", numbers: []json.Number{json.Number("1" + strings.Repeat("0", MaxBigIntDigits)), "2"}, code: CodeOutOfRange},
+	}
+	for _, tt := range tests {
+		spec, _ := lookupBigIntOperation(tt.op)
+		numbers, names := tt.numbers, make([]string, len(tt.numbers))
+		for i := range names {
+			names[i] = "n"
+		}
+
+		resp, err := spec.run(numbers, names)
+		if tt.code != "" {
+			if err == nil || asOperationError(err).Code != tt.code {
+				t.Errorf("%s of %d operands: got %v, want %s", tt.op, len(numbers), err, tt.code)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s of %d operands: unexpected error: %v", tt.op, len(numbers), err)
+			continue
+		}
+		var got string
+		switch resp := resp.(type) {
+		case BigIntResponse:
+			got = resp.Result
+		case PrimeResponse:
+			got = map[bool]string{true: "true", false: "false"}[resp.Result]
+		}
+		if tt.digits != 0 && len(got) != tt.digits {
+			t.Errorf("%s of %d operands: got %d digits, want %d", tt.op, len(numbers), len(got), tt.digits)
+		}
+		if tt.want != "" && got != tt.want {
+			t.Errorf("%s(%v): got %s, want %s", tt.op, numbers, got, tt.want)
+		}
+	}
+}
+
+func TestResultTooLarge(t *testing.T) {
+	limit := new(big.Int).Set(bigIntResultLimit)
+	largest := new(big.Int).Sub(limit, big.NewInt(1))
+
+	tests := []struct {
+		name string
+		x    *big.Int
+		want bool
+	}{
+		{"zero", new(big.Int), false},
+		{"largest allowed", largest, false},
+		{"largest allowed, negative", new(big.Int).Neg(largest), false},
+		{"one digit too many", limit, true},
+		{"one digit too many, negative", new(big.Int).Neg(limit), true},
+		{"far too many", new(big.Int).Lsh(limit, 100), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resultTooLarge(tt.x); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"cloudprojects/calculator-backend-api/cache"
+
+	"golang.org/x/exp/slog"
+)
+
+// ResultCache remembers the outcome of recent calculations by operation and
+// operands, so that repeated identical requests skip the computation.
+type ResultCache struct {
+	lru    *cache.LRU[string, cachedResult]
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	logger *slog.Logger
+}
+
+type cachedResult struct {
+	result any
+	err    error
+}
+
+type resultCacheKey struct{}
+
+// NewResultCache returns a cache of the size most recent calculations.
+func NewResultCache(size int, logger *slog.Logger) *ResultCache {
+	return &ResultCache{lru: cache.New[string, cachedResult](size, 0), logger: logger}
+}
+
+// ResultCacheMiddleware makes c available to the calculation handlers.
+func ResultCacheMiddleware(next http.Handler, c *ResultCache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), resultCacheKey{}, c)))
+	})
+}
+
+// cacheKey identifies a calculation by a hash of its operation and operands,
+// so that keys stay small however many operands there are. Floats format
+// with the shortest representation that round-trips and strings are quoted,
+// so distinct operands never hash the same text.
+func cacheKey(operation string, operands ...any) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q", operation)
+	for _, operand := range operands {
+		fmt.Fprintf(h, " %#v", operand)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cachedCall returns the cached outcome of the calculation identified by key,
+// computing and caching it on a miss. Without a ResultCache on r it always
+// computes.
+func cachedCall[T any](r *http.Request, key string, compute func() (T, error)) (T, error) {
+	c, ok := r.Context().Value(resultCacheKey{}).(*ResultCache)
+	if !ok {
+		return compute()
+	}
+
+	if cached, ok := c.lru.Get(key); ok {
+		c.hits.Add(1)
+		setLogCache(r, "hit")
+		c.logger.Debug("Result cache hit", "key", key, "hits", c.hits.Load(), "misses", c.misses.Load())
+		return cached.result.(T), cached.err
+	}
+	c.misses.Add(1)
+	setLogCache(r, "miss")
+	c.logger.Debug("Result cache miss", "key", key, "hits", c.hits.Load(), "misses", c.misses.Load(), "size", c.lru.Len())
+
+	result, err := compute()
+	if err == nil || asOperationError(err) != ErrInternal {
+		c.lru.Add(key, cachedResult{result: result, err: err})
+	}
+	return result, err
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (c *ResultCache) Stats() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
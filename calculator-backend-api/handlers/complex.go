@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"math/cmplx"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// ComplexRequest is the body accepted by binary complex operations such as
+// /complex/add. Operands are strings in the form "a+bi", such as "3+4i",
+// "-2.5i" or "7".
+type ComplexRequest struct {
+	A string `json:"number1"`
+	B string `json:"number2"`
+}
+
+// ComplexUnaryRequest is the body accepted by single-operand complex
+// operations such as /complex/sqrt.
+type ComplexUnaryRequest struct {
+	Number string `json:"number"`
+}
+
+// ComplexResponse holds the result of a complex operation, both in the
+// "a+bi" form and as its real and imaginary parts.
+type ComplexResponse struct {
+	Result string  `json:"result"`
+	Real   float64 `json:"real"`
+	Imag   float64 `json:"imag"`
+}
+
+// complexOperationSpec declares an operation on complex numbers.
+type complexOperationSpec struct {
+	name    string
+	summary string
+	arity   int
+	apply   func(x []complex128) (complex128, error)
+}
+
+// complexOperations is the registry of complex operations, each served at
+// /complex/<name>.
+var complexOperations = []complexOperationSpec{
+	{name: "add", summary: "Add two complex numbers", arity: 2,
+		apply: func(x []complex128) (complex128, error) { return x[0] + x[1], nil }},
+	{name: "subtract", summary: "Subtract complex number2 from number1", arity: 2,
+		apply: func(x []complex128) (complex128, error) { return x[0] - x[1], nil }},
+	{name: "multiply", summary: "Multiply two complex numbers", arity: 2,
+		apply: func(x []complex128) (complex128, error) { return x[0] * x[1], nil }},
+	{name: "divide", summary: "Divide complex number1 by number2", arity: 2, apply: complexDivide},
+	{name: "power", summary: "Raise complex number1 to the power of number2", arity: 2,
+		apply: func(x []complex128) (complex128, error) { return cmplx.Pow(x[0], x[1]), nil }},
+	{name: "abs", summary: "Modulus of a complex number", arity: 1,
+		apply: func(x []complex128) (complex128, error) { return complex(cmplx.Abs(x[0]), 0), nil }},
+	{name: "arg", summary: "Argument of a complex number, in radians", arity: 1,
+		apply: func(x []complex128) (complex128, error) { return complex(cmplx.Phase(x[0]), 0), nil }},
+	{name: "conjugate", summary: "Complex conjugate", arity: 1,
+		apply: func(x []complex128) (complex128, error) { return cmplx.Conj(x[0]), nil }},
+	{name: "sqrt", summary: "Principal square root of a complex number", arity: 1,
+		apply: func(x []complex128) (complex128, error) { return cmplx.Sqrt(x[0]), nil }},
+	{name: "exp", summary: "Exponential of a complex number", arity: 1,
+		apply: func(x []complex128) (complex128, error) { return cmplx.Exp(x[0]), nil }},
+	{name: "ln", summary: "Principal natural logarithm of a complex number", arity: 1, apply: complexLog},
+}
+
+// lookupComplexOperation finds a complex operation by name.
+func lookupComplexOperation(name string) (*complexOperationSpec, bool) {
+	i := slices.IndexFunc(complexOperations, func(spec complexOperationSpec) bool { return spec.name == name })
+	if i < 0 {
+		return nil, false
+	}
+	return &complexOperations[i], true
+}
+
+// ComplexOperationNames returns the names of the complex operations, in
+// registration order.
+func ComplexOperationNames() []string {
+	names := make([]string, len(complexOperations))
+	for i, spec := range complexOperations {
+		names[i] = spec.name
+	}
+	return names
+}
+
+func complexDivide(x []complex128) (complex128, error) {
+	if x[1] == 0 {
+		return 0, ErrDivisionByZero
+	}
+	return x[0] / x[1], nil
+}
+
+func complexLog(x []complex128) (complex128, error) {
+	if x[0] == 0 {
+		return 0, domainError(FieldError{Field: "number", Reason: "must not be 0"})
+	}
+	return cmplx.Log(x[0]), nil
+}
+
+// unitImaginary matches an imaginary part without digits, as in "i" or "3-i".
+var unitImaginary = regexp.MustCompile(`(^|[(+-])i(\)?)$`)
+
+// parseComplex parses the operand in field, which is a complex number in the
+// form accepted by strconv.ParseComplex, or with an imaginary part of just
+// "i".
+func parseComplex(field, s string) (complex128, error) {
+	s = unitImaginary.ReplaceAllString(strings.ReplaceAll(s, " ", ""), "${1}1i$2")
+	c, err := strconv.ParseComplex(s, 128)
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) && numErr.Err == strconv.ErrRange || err == nil && !isFiniteComplex(c) {
+		return 0, &OperationError{
+			Code:    CodeOutOfRange,
+			Status:  http.StatusBadRequest,
+			Message: ErrOutOfRange.Message,
+			Fields:  []FieldError{{Field: field, Reason: "is out of range"}},
+		}
+	}
+	if err != nil {
+		return 0, invalidPayload(FieldError{Field: field, Reason: `must be a complex number such as "3+4i"`})
+	}
+	return c, nil
+}
+
+func isFiniteComplex(c complex128) bool {
+	return !cmplx.IsNaN(c) && !cmplx.IsInf(c)
+}
+
+// formatComplex formats c as "a+bi", without the parentheses
+// strconv.FormatComplex adds.
+func formatComplex(c complex128) string {
+	s := strconv.FormatComplex(c, 'g', -1, 128)
+	return strings.TrimSuffix(strings.TrimPrefix(s, "("), ")")
+}
+
+// run parses the operands, applies the operation and validates the result.
+func (spec *complexOperationSpec) run(operands []string) (complex128, error) {
+	names := []string{"number"}
+	if spec.arity == 2 {
+		names = []string{"number1", "number2"}
+	}
+	x := make([]complex128, len(operands))
+	for i, s := range operands {
+		c, err := parseComplex(names[i], s)
+		if err != nil {
+			return 0, err
+		}
+		x[i] = c
+	}
+
+	result, err := spec.apply(x)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case cmplx.IsInf(result):
+		return 0, ErrOverflow
+	case cmplx.IsNaN(result):
+		return 0, ErrNaN
+	}
+	// Adding zero turns negative zeros positive.
+	return complex(real(result)+0, imag(result)+0), nil
+}
+
+// ComplexHandler serves the complex operation named op. It panics if op is
+// not registered.
+func ComplexHandler(logger *slog.Logger, op string) http.HandlerFunc {
+	spec, ok := lookupComplexOperation(op)
+	if !ok {
+		panic("handlers: unknown complex operation " + op)
+	}
+	name := "complex/" + spec.name
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(logger, r).With("operation", name)
+		setLogOperation(r, name)
+
+		var operands []string
+		var err error
+		if spec.arity == 1 {
+			var req ComplexUnaryRequest
+			err = decodeRequest(r, &req)
+			operands = []string{req.Number}
+		} else {
+			var req ComplexRequest
+			err = decodeRequest(r, &req)
+			operands = []string{req.A, req.B}
+		}
+		if err != nil {
+			handleDecodeError(logger, w, r, err)
+			return
+		}
+
+		recorded := make([]any, len(operands))
+		for i, s := range operands {
+			recorded[i] = s
+		}
+		resp, err := cachedCall(r, cacheKey(name, recorded...), func() (ComplexResponse, error) {
+			result, err := spec.run(operands)
+			return ComplexResponse{Result: formatComplex(result), Real: real(result), Imag: imag(result)}, err
+		})
+		recordCall(r, name, recorded, resp.Result, err)
+		if err != nil {
+			writeError(logger, w, err)
+			return
+		}
+		writeJSON(logger, w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cloudprojects/calculator-backend-api/api"
+	"cloudprojects/calculator-backend-api/decimal"
+	"golang.org/x/exp/slog"
+)
+
+// PrecisionHeader opts a request into decimal precision mode when set to
+// "decimal", as an alternative to the "precision" body field.
+const PrecisionHeader = api.PrecisionHeader
+
+const precisionDecimal = "decimal"
+
+// DefaultDivisionScale is the number of fractional digits a decimal division
+// is rounded to when the request does not specify a scale.
+const DefaultDivisionScale = 16
+
+// DecimalOptions are the body fields that select and configure decimal
+// precision mode. Without a scale, add, subtract and multiply return exact
+// results.
+type DecimalOptions struct {
+	Precision string `json:"precision,omitempty"`
+	Scale     *int   `json:"scale,omitempty"`
+	Rounding  string `json:"rounding,omitempty"`
+}
+
+// DecimalRequest is the decimal mode body accepted by /add, /subtract and
+// /multiply. Operands may be JSON numbers or decimal strings.
+type DecimalRequest struct {
+	A json.Number `json:"number1"`
+	B json.Number `json:"number2"`
+	DecimalOptions
+}
+
+// DecimalDivideRequest is the decimal mode body accepted by /divide.
+type DecimalDivideRequest struct {
+	Dividend json.Number `json:"dividend"`
+	Divisor  json.Number `json:"divisor"`
+	DecimalOptions
+}
+
+// DecimalResponse is returned by operations in decimal precision mode.
+type DecimalResponse struct {
+	Result string `json:"result"`
+}
+
+type decimalOperandRequest interface {
+	decimalOperands() (json.Number, json.Number)
+	options() DecimalOptions
+}
+
+func (req *DecimalRequest) decimalOperands() (json.Number, json.Number) { return req.A, req.B }
+
+func (req *DecimalRequest) options() DecimalOptions { return req.DecimalOptions }
+
+func (req *DecimalDivideRequest) decimalOperands() (json.Number, json.Number) {
+	return req.Dividend, req.Divisor
+}
+
+func (req *DecimalDivideRequest) options() DecimalOptions { return req.DecimalOptions }
+
+// decimalOperation is a binary operation in decimal precision mode. The scale
+// is only used by operations that cannot be computed exactly.
+type decimalOperation func(a, b decimal.Decimal, scale int, mode decimal.RoundingMode) (decimal.Decimal, error)
+
+func decimalAdd(a, b decimal.Decimal, _ int, _ decimal.RoundingMode) (decimal.Decimal, error) {
+	return a.Add(b), nil
+}
+
+func decimalSubtract(a, b decimal.Decimal, _ int, _ decimal.RoundingMode) (decimal.Decimal, error) {
+	return a.Sub(b), nil
+}
+
+func decimalMultiply(a, b decimal.Decimal, _ int, _ decimal.RoundingMode) (decimal.Decimal, error) {
+	return a.Mul(b), nil
+}
+
+func decimalDivide(a, b decimal.Decimal, scale int, mode decimal.RoundingMode) (decimal.Decimal, error) {
+	result, err := a.Quo(b, scale, mode)
+	if errors.Is(err, decimal.ErrDivisionByZero) {
+		return decimal.Decimal{}, ErrDivisionByZero
+	}
+	return result, err
+}
+
+// isDecimalMode reports whether the request opted into decimal precision
+// mode, through either PrecisionHeader or the body's "precision" field.
+func isDecimalMode(r *http.Request, body []byte) (bool, error) {
+	if strings.EqualFold(r.Header.Get(PrecisionHeader), precisionDecimal) {
+		return true, nil
+	}
+	var probe struct {
+		Precision *string `json:"precision"`
+	}
+	if json.Unmarshal(body, &probe) != nil || probe.Precision == nil {
+		return false, nil
+	}
+	if !strings.EqualFold(*probe.Precision, precisionDecimal) {
+		return false, invalidPayload(FieldError{Field: "precision", Reason: `must be "decimal"`})
+	}
+	return true, nil
+}
+
+// applyDecimalOperation parses the operands, runs op and rounds the result
+// to the requested scale.
+func applyDecimalOperation(op decimalOperation, a, b json.Number, opts DecimalOptions) (decimal.Decimal, error) {
+	mode, err := decimal.ParseRoundingMode(opts.Rounding)
+	if err != nil {
+		return decimal.Decimal{}, ErrInvalidRounding
+	}
+	scale := DefaultDivisionScale
+	if opts.Scale != nil {
+		scale = *opts.Scale
+	}
+	if scale < 0 || scale > decimal.MaxScale {
+		return decimal.Decimal{}, ErrInvalidScale
+	}
+
+	x, err := parseDecimal(a)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	y, err := parseDecimal(b)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	result, err := op(x, y, scale, mode)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	if opts.Scale != nil {
+		return result.Round(scale, mode)
+	}
+	return result, nil
+}
+
+func parseDecimal(n json.Number) (decimal.Decimal, error) {
+	d, err := decimal.Parse(n.String())
+	if errors.Is(err, decimal.ErrTooLarge) {
+		return decimal.Decimal{}, ErrOutOfRange
+	}
+	if err != nil {
+		return decimal.Decimal{}, ErrInvalidDecimal
+	}
+	return d, nil
+}
+
+func handleDecimalOperation(logger *slog.Logger, w http.ResponseWriter, r *http.Request, body []byte, req decimalOperandRequest, op binaryOperation) {
+	if err := decodeBody(body, req); err != nil {
+		handleDecodeError(logger, w, r, err)
+		return
+	}
+
+	a, b := req.decimalOperands()
+	opts := req.options()
+	scale := "default"
+	if opts.Scale != nil {
+		scale = strconv.Itoa(*opts.Scale)
+	}
+	key := cacheKey(op.name+"/decimal", a.String(), b.String(), scale, opts.Rounding)
+	result, err := cachedCall(r, key, func() (decimal.Decimal, error) { return applyDecimalOperation(op.decimal, a, b, opts) })
+	recordCall(r, op.name, []any{a.String(), b.String()}, result.String(), err)
+	recordPrecision(r, precisionDecimal)
+	if err != nil {
+		writeError(logger, w, err)
+		return
+	}
+	writeJSON(logger, w, http.StatusOK, DecimalResponse{Result: result.String()})
+}
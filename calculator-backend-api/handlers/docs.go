@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cloudprojects/calculator-backend-api/buildinfo"
+	"cloudprojects/calculator-backend-api/openapi"
+)
+
+var (
+	requestIDParam = openapi.Parameter{Name: RequestIDHeader, In: "header", Type: "string",
+		Description: "Client supplied request ID, echoed in the response and logs."}
+	idempotencyKeyParam = openapi.Parameter{Name: IdempotencyKeyHeader, In: "header", Type: "string",
+		Description: "Replays the first response to requests that repeat this key."}
+	precisionParam = openapi.Parameter{Name: PrecisionHeader, In: "header", Type: "string",
+		Description: `Set to "decimal" for exact decimal arithmetic.`}
+)
+
+// calculationDoc documents a calculation endpoint.
+func calculationDoc(summary string, request, response any, params ...openapi.Parameter) openapi.Operation {
+	return openapi.Operation{
+		Method:        http.MethodPost,
+		Summary:       summary,
+		Scope:         "calculate",
+		Parameters:    append([]openapi.Parameter{requestIDParam, idempotencyKeyParam}, params...),
+		Request:       request,
+		Response:      response,
+		RequestTypes:  RequestMediaTypes,
+		ResponseTypes: ResponseMediaTypes,
+		Errors:        []int{http.StatusNotAcceptable, http.StatusConflict, http.StatusUnprocessableEntity},
+	}
+}
+
+// decimalDoc documents a binary operation that supports decimal mode.
+func decimalDoc(summary string, request, decimalRequest any) openapi.Operation {
+	return calculationDoc(summary, openapi.OneOf{request, decimalRequest}, openapi.OneOf{Response{}, DecimalResponse{}}, precisionParam)
+}
+
+// routeDocs documents every HTTP route except the registered operations,
+// which are documented from the registry.
+var routeDocs = map[string][]openapi.Operation{
+	"/add":      {decimalDoc("Add two numbers", Request{}, DecimalRequest{})},
+	"/subtract": {decimalDoc("Subtract number2 from number1", Request{}, DecimalRequest{})},
+	"/multiply": {decimalDoc("Multiply two numbers", Request{}, DecimalRequest{})},
+	"/divide":   {decimalDoc("Divide the dividend by the divisor", DivideRequest{}, DecimalDivideRequest{})},
+	"/sum":      {calculationDoc("Sum an array of numbers", SumRequest{}, Response{})},
+	"/evaluate": {calculationDoc("Evaluate an arithmetic expression", EvaluateRequest{}, Response{})},
+	"/convert-units": {calculationDoc("Convert a value between units of the same dimension",
+		ConvertUnitsRequest{}, ConvertUnitsResponse{})},
+	"/batch": {calculationDoc("Evaluate many binary operations at once", BatchRequest{}, BatchResponse{})},
+	"/history": {
+		{
+			Method:  http.MethodGet,
+			Summary: "List past calculations, newest first",
+			Description: "The operands and result of /add, /subtract, /multiply and /divide are numbers, or decimal strings " +
+				`for calculations made in decimal precision mode, whose entries have "precision": "decimal".`,
+			Scope: "history",
+			Parameters: []openapi.Parameter{
+				requestIDParam,
+				{Name: "limit", In: "query", Type: "integer", Description: "Entries per page, at most " + strconv.Itoa(maxHistoryLimit) + "."},
+				{Name: "offset", In: "query", Type: "integer"},
+				{Name: "operation", In: "query", Type: "string"},
+				{Name: "from", In: "query", Type: "string", Format: "date-time"},
+				{Name: "to", In: "query", Type: "string", Format: "date-time"},
+			},
+			Response: HistoryResponse{},
+			Errors:   []int{http.StatusBadRequest},
+		},
+		{
+			Method:  http.MethodDelete,
+			Summary: "Delete calculations from before a time",
+			Scope:   "history",
+			Parameters: []openapi.Parameter{
+				requestIDParam,
+				{Name: "before", In: "query", Type: "string", Format: "date-time", Required: true},
+			},
+			Response: DeleteHistoryResponse{},
+			Errors:   []int{http.StatusBadRequest},
+		},
+	},
+	"/ws": {{
+		Method:  http.MethodGet,
+		Summary: "Open a calculator session over a WebSocket",
+		Description: "Upgrades to a WebSocket that accepts SessionRequest messages and answers each with a SessionResponse. " +
+			"The first message names the session, which can be resumed with the session parameter until it expires.",
+		Scope: "calculate",
+		Parameters: []openapi.Parameter{
+			requestIDParam,
+			{Name: "session", In: "query", Type: "string", Description: "ID of a session to resume."},
+		},
+		Response: SessionResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusServiceUnavailable},
+	}},
+	"/healthz": {{Method: http.MethodGet, Summary: "Liveness probe", Response: HealthResponse{}}},
+	"/readyz": {{Method: http.MethodGet, Summary: "Readiness probe, checking the server's dependencies",
+		Description: "Responds 503 with the same body when a check fails.", Response: HealthResponse{}}},
+	"/version":      {{Method: http.MethodGet, Summary: "Build commit, Go version and start time", Response: buildinfo.Info{}}},
+	"/metrics":      {{Method: http.MethodGet, Summary: "Prometheus metrics", ContentType: "text/plain"}},
+	"/openapi.json": {{Method: http.MethodGet, Summary: "This OpenAPI document"}},
+	"/docs":         {{Method: http.MethodGet, Summary: "Interactive API documentation", ContentType: "text/html"}},
+	"/":             {{Method: http.MethodGet, Summary: "Calculator web UI", ContentType: "text/html"}},
+}
+
+// RouteDocs returns the OpenAPI operations served at pattern, or nil if the
+// route is not documented.
+func RouteDocs(pattern string) []openapi.Operation {
+	if ops, ok := routeDocs[pattern]; ok {
+		return ops
+	}
+	if name, ok := strings.CutPrefix(pattern, "/complex/"); ok {
+		spec, ok := lookupComplexOperation(name)
+		if !ok {
+			return nil
+		}
+		var request any = ComplexRequest{}
+		if spec.arity == 1 {
+			request = ComplexUnaryRequest{}
+		}
+		return []openapi.Operation{calculationDoc(spec.summary, request, ComplexResponse{})}
+	}
+	if name, ok := strings.CutPrefix(pattern, "/matrix/"); ok {
+		spec, ok := lookupMatrixOperation(name)
+		if !ok {
+			return nil
+		}
+		return []openapi.Operation{calculationDoc(spec.summary, spec.request, spec.response)}
+	}
+	if name, ok := strings.CutPrefix(pattern, "/bigint/"); ok {
+		spec, ok := lookupBigIntOperation(name)
+		if !ok {
+			return nil
+		}
+		return []openapi.Operation{calculationDoc(spec.summary, spec.request, spec.response)}
+	}
+	spec, ok := lookupOperation(strings.TrimPrefix(pattern, "/"))
+	if !ok {
+		return nil
+	}
+	var request any
+	switch spec.arity {
+	case 1:
+		request = UnaryRequest{}
+	case 2:
+		request = Request{}
+	case variadic:
+		request = ArrayRequest{}
+		if spec.param != "" {
+			request = PercentileRequest{}
+		}
+	}
+	return []openapi.Operation{calculationDoc(spec.summary, request, Response{})}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"cloudprojects/calculator-backend-api/api"
+)
+
+// ErrorCode is the stable, machine-readable identifier returned in
+// Response.Error when an operation fails. The codes are defined in the api
+// package, which clients share.
+type ErrorCode = api.ErrorCode
+
+const (
+	CodeDivisionByZero    = api.CodeDivisionByZero
+	CodeOverflow          = api.CodeOverflow
+	CodeNaN               = api.CodeNaN
+	CodeOutOfRange        = api.CodeOutOfRange
+	CodeDomainError       = api.CodeDomainError
+	CodeIncompatibleUnits = api.CodeIncompatibleUnits
+	CodeDimensionMismatch = api.CodeDimensionMismatch
+	CodeSingularMatrix    = api.CodeSingularMatrix
+	CodeMatrixTooLarge    = api.CodeMatrixTooLarge
+	CodeSyntaxError       = api.CodeSyntaxError
+	CodeInvalidDecimal    = api.CodeInvalidDecimal
+	CodeInvalidScale      = api.CodeInvalidScale
+	CodeInvalidRounding   = api.CodeInvalidRounding
+	CodeInvalidPayload    = api.CodeInvalidPayload
+	CodePayloadTooLarge   = api.CodePayloadTooLarge
+	CodeUnsupportedType   = api.CodeUnsupportedType
+	CodeNotFound          = api.CodeNotFound
+	CodeMethodNotAllowed  = api.CodeMethodNotAllowed
+	CodeNotAcceptable     = api.CodeNotAcceptable
+	CodeUnknownOp         = api.CodeUnknownOp
+	CodeIdempotencyInUse  = api.CodeIdempotencyInUse
+	CodeIdempotencyReuse  = api.CodeIdempotencyReuse
+	CodeBatchTooLarge     = api.CodeBatchTooLarge
+	CodeSessionNotFound   = api.CodeSessionNotFound
+	CodeSessionInUse      = api.CodeSessionInUse
+	CodeTooManySessions   = api.CodeTooManySessions
+	CodeUnauthorized      = api.CodeUnauthorized
+	CodeForbidden         = api.CodeForbidden
+	CodeRateLimited       = api.CodeRateLimited
+	CodeInternal          = api.CodeInternal
+)
+
+// OperationError is returned by the calculator operations. Each error carries
+// the code reported to the client and the HTTP status it is served with.
+type OperationError struct {
+	Code    ErrorCode
+	Status  int
+	Message string
+	// Fields lists the offending request fields, if any.
+	Fields []FieldError
+}
+
+func (e *OperationError) Error() string {
+	return e.Message
+}
+
+// ErrorCode returns e.Code. It lets errors from the client package match e
+// with errors.Is.
+func (e *OperationError) ErrorCode() ErrorCode {
+	return e.Code
+}
+
+// response returns the error body served to the client.
+func (e *OperationError) response() Response {
+	return Response{Error: string(e.Code), Message: e.Message, Fields: e.Fields}
+}
+
+var (
+	ErrDivisionByZero       = &OperationError{Code: CodeDivisionByZero, Status: http.StatusBadRequest, Message: "division by zero"}
+	ErrOverflow             = &OperationError{Code: CodeOverflow, Status: http.StatusUnprocessableEntity, Message: "result overflows the range of a float64"}
+	ErrNaN                  = &OperationError{Code: CodeNaN, Status: http.StatusUnprocessableEntity, Message: "result is not a number"}
+	ErrOutOfRange           = &OperationError{Code: CodeOutOfRange, Status: http.StatusBadRequest, Message: "operand is out of range"}
+	ErrSingularMatrix       = &OperationError{Code: CodeSingularMatrix, Status: http.StatusBadRequest, Message: "matrix is singular"}
+	ErrMatrixTooLarge       = &OperationError{Code: CodeMatrixTooLarge, Status: http.StatusRequestEntityTooLarge, Message: "matrix has too many elements"}
+	ErrInvalidDecimal       = &OperationError{Code: CodeInvalidDecimal, Status: http.StatusBadRequest, Message: "operand is not a valid decimal number"}
+	ErrInvalidScale         = &OperationError{Code: CodeInvalidScale, Status: http.StatusBadRequest, Message: "scale is out of range"}
+	ErrInvalidRounding      = &OperationError{Code: CodeInvalidRounding, Status: http.StatusBadRequest, Message: "unknown rounding mode"}
+	ErrUnsupportedMediaType = &OperationError{Code: CodeUnsupportedType, Status: http.StatusUnsupportedMediaType, Message: "Content-Type must be application/json, application/x-www-form-urlencoded or application/cbor"}
+	ErrNotFound             = &OperationError{Code: CodeNotFound, Status: http.StatusNotFound, Message: "not found"}
+	ErrMethodNotAllowed     = &OperationError{Code: CodeMethodNotAllowed, Status: http.StatusMethodNotAllowed, Message: "method not allowed"}
+	ErrNotAcceptable        = &OperationError{Code: CodeNotAcceptable, Status: http.StatusNotAcceptable, Message: "Accept must allow application/json, application/cbor, application/xml or text/plain"}
+	ErrUnknownOp            = &OperationError{Code: CodeUnknownOp, Status: http.StatusBadRequest, Message: "unknown operation"}
+	ErrIdempotencyInUse     = &OperationError{Code: CodeIdempotencyInUse, Status: http.StatusConflict, Message: "a request with this idempotency key is still in progress"}
+	ErrIdempotencyReuse     = &OperationError{Code: CodeIdempotencyReuse, Status: http.StatusUnprocessableEntity, Message: "idempotency key was already used for a different request"}
+	ErrBatchTooLarge        = &OperationError{Code: CodeBatchTooLarge, Status: http.StatusRequestEntityTooLarge, Message: "batch has too many items"}
+	ErrSessionNotFound      = &OperationError{Code: CodeSessionNotFound, Status: http.StatusNotFound, Message: "session does not exist or has expired"}
+	ErrSessionInUse         = &OperationError{Code: CodeSessionInUse, Status: http.StatusConflict, Message: "session is already open on another connection"}
+	ErrTooManySessions      = &OperationError{Code: CodeTooManySessions, Status: http.StatusServiceUnavailable, Message: "too many open sessions, retry later"}
+	ErrMissingToken         = &OperationError{Code: CodeUnauthorized, Status: http.StatusUnauthorized, Message: "missing bearer token"}
+	ErrInvalidToken         = &OperationError{Code: CodeUnauthorized, Status: http.StatusUnauthorized, Message: "invalid bearer token"}
+	ErrExpiredToken         = &OperationError{Code: CodeUnauthorized, Status: http.StatusUnauthorized, Message: "bearer token has expired"}
+	ErrForbidden            = &OperationError{Code: CodeForbidden, Status: http.StatusForbidden, Message: "token does not grant access to this resource"}
+	ErrRateLimited          = &OperationError{Code: CodeRateLimited, Status: http.StatusTooManyRequests, Message: "rate limit exceeded, retry later"}
+	ErrInternal             = &OperationError{Code: CodeInternal, Status: http.StatusInternalServerError, Message: "internal server error"}
+)
+
+// domainError reports operands outside the domain of an operation, such as
+// the logarithm of a negative number.
+func domainError(fields ...FieldError) *OperationError {
+	return &OperationError{
+		Code:    CodeDomainError,
+		Status:  http.StatusBadRequest,
+		Message: "operand is outside the domain of the operation",
+		Fields:  fields,
+	}
+}
+
+// asOperationError maps any error onto an OperationError, falling back to
+// ErrInternal for errors that did not originate from an operation.
+func asOperationError(err error) *OperationError {
+	var opErr *OperationError
+	if errors.As(err, &opErr) {
+		return opErr
+	}
+	return ErrInternal
+}
+
+// checkOperands rejects operands that are not finite numbers.
+func checkOperands(operands ...float64) error {
+	for _, n := range operands {
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return ErrOutOfRange
+		}
+	}
+	return nil
+}
+
+// checkResult rejects results that overflowed or are not a number.
+func checkResult(result float64) error {
+	switch {
+	case math.IsNaN(result):
+		return ErrNaN
+	case math.IsInf(result, 0):
+		return ErrOverflow
+	}
+	return nil
+}
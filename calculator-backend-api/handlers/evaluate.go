@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"cloudprojects/calculator-backend-api/expr"
+	"golang.org/x/exp/slog"
+)
+
+// EvaluateRequest is the body accepted by /evaluate.
+type EvaluateRequest struct {
+	Expression string `json:"expression"`
+}
+
+// Evaluate evaluates an expression, translating expr errors into operation
+// errors. Syntax errors are returned as *expr.SyntaxError.
+func Evaluate(expression string) (float64, error) {
+	result, err := expr.Evaluate(expression)
+	switch {
+	case errors.Is(err, expr.ErrDivisionByZero):
+		return 0, ErrDivisionByZero
+	case errors.Is(err, expr.ErrOverflow):
+		return 0, ErrOverflow
+	case errors.Is(err, expr.ErrNaN):
+		return 0, ErrNaN
+	case err != nil:
+		return 0, err
+	}
+	return result, nil
+}
+
+func EvaluateHandler(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(logger, r).With("operation", "evaluate")
+		setLogOperation(r, "evaluate")
+		var req EvaluateRequest
+		if err := decodeRequest(r, &req); err != nil {
+			handleDecodeError(logger, w, r, err)
+			return
+		}
+
+		result, err := cachedCall(r, cacheKey("evaluate", req.Expression), func() (float64, error) { return Evaluate(req.Expression) })
+		recordCall(r, "evaluate", []any{req.Expression}, result, err)
+		var syntaxErr *expr.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			logger.Error("Invalid expression", "expression", req.Expression, "error", err)
+			writeResponse(logger, w, http.StatusBadRequest, Response{
+				Error:    string(CodeSyntaxError),
+				Message:  syntaxErr.Msg,
+				Position: &syntaxErr.Pos,
+			})
+			return
+		}
+		if err != nil {
+			writeError(logger, w, err)
+			return
+		}
+		writeResponse(logger, w, http.StatusOK, Response{Result: result})
+	}
+}
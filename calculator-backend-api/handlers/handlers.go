@@ -2,73 +2,281 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	// "time"
 
+	"cloudprojects/calculator-backend-api/api"
 	"golang.org/x/exp/slog"
 )
 
-type Request struct {
-	A float64 `json:"a"`
-	B float64 `json:"b"`
+// The bodies of the basic operations and of every response are defined in
+// the api package, which clients share.
+type (
+	Request       = api.Request
+	DivideRequest = api.DivideRequest
+	SumRequest    = api.SumRequest
+	Response      = api.Response
+)
+
+// binaryOperands returns the operands of a decoded /add, /subtract,
+// /multiply or /divide request body.
+func binaryOperands(req any) (float64, float64) {
+	switch req := req.(type) {
+	case *Request:
+		return req.A, req.B
+	case *DivideRequest:
+		return req.Dividend, req.Divisor
+	}
+	panic(fmt.Sprintf("handlers: unsupported binary operation request %T", req))
+}
+
+// decimalBody returns an empty request with the same field names as req, for
+// decoding the body in decimal precision mode.
+func decimalBody(req any) decimalOperandRequest {
+	if _, ok := req.(*DivideRequest); ok {
+		return &DecimalDivideRequest{}
+	}
+	return &DecimalRequest{}
+}
+
+// operation is a binary calculator operation.
+type operation func(a, b float64) (float64, error)
+
+// binaryOperation pairs an operation with its decimal precision counterpart.
+type binaryOperation struct {
+	name    string
+	float   operation
+	decimal decimalOperation
 }
 
-type Response struct {
-	Result float64 `json:"result"`
-	Error  string  `json:"error,omitempty"`
+var (
+	addOperation      = binaryOperation{name: "add", float: add, decimal: decimalAdd}
+	subtractOperation = binaryOperation{name: "subtract", float: subtract, decimal: decimalSubtract}
+	multiplyOperation = binaryOperation{name: "multiply", float: multiply, decimal: decimalMultiply}
+	divideOperation   = binaryOperation{name: "divide", float: divide, decimal: decimalDivide}
+)
+
+// binaryOperations looks up a binary operation by name.
+var binaryOperations = map[string]binaryOperation{
+	addOperation.name:      addOperation,
+	subtractOperation.name: subtractOperation,
+	multiplyOperation.name: multiplyOperation,
+	divideOperation.name:   divideOperation,
+}
+
+func add(a, b float64) (float64, error) { return a + b, nil }
+
+func subtract(a, b float64) (float64, error) { return a - b, nil }
+
+func multiply(a, b float64) (float64, error) { return a * b, nil }
+
+func divide(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, ErrDivisionByZero
+	}
+	return a / b, nil
+}
+
+func sum(numbers []float64) (float64, error) {
+	var result float64
+	for _, n := range numbers {
+		result += n
+	}
+	return result, nil
+}
+
+// applyOperation validates the operands, runs op and validates its result.
+func applyOperation(op operation, a, b float64) (float64, error) {
+	if err := checkOperands(a, b); err != nil {
+		return 0, err
+	}
+	result, err := op(a, b)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkResult(result); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+// Calculate applies the binary operation named op, one of add, subtract,
+// multiply, divide or a two-operand registered operation such as power,
+// exactly as the HTTP endpoints do.
+func Calculate(op string, a, b float64) (float64, error) {
+	operation, ok := binaryOperations[op]
+	if !ok {
+		return Apply(op, []float64{a, b}, nil)
+	}
+	return applyOperation(operation.float, a, b)
+}
+
+// Sum validates the operands, sums them and validates the result.
+func Sum(numbers []float64) (float64, error) {
+	if err := checkOperands(numbers...); err != nil {
+		return 0, err
+	}
+	result, err := sum(numbers)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkResult(result); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+func floatOperands(numbers ...float64) []any {
+	operands := make([]any, len(numbers))
+	for i, n := range numbers {
+		operands[i] = n
+	}
+	return operands
 }
 
 func AddHandler(logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		handleOperation(logger, w, r, func(a, b float64) float64 { return a + b })
+		handleOperation(logger, w, r, &Request{}, addOperation)
 	}
 }
 
 func SubtractHandler(logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		handleOperation(logger, w, r, func(a, b float64) float64 { return a - b })
+		handleOperation(logger, w, r, &Request{}, subtractOperation)
 	}
 }
 
 func MultiplyHandler(logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		handleOperation(logger, w, r, func(a, b float64) float64 { return a * b })
+		handleOperation(logger, w, r, &Request{}, multiplyOperation)
 	}
 }
 
 func DivideHandler(logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		handleOperation(logger, w, r, func(a, b float64) float64 {
-			if b == 0 {
-				panic("division by zero")
-			}
-			return a / b
-		})
+		handleOperation(logger, w, r, &DivideRequest{}, divideOperation)
 	}
 }
 
-func handleOperation(logger *slog.Logger, w http.ResponseWriter, r *http.Request, operation func(float64, float64) float64) {
+func SumHandler(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(logger, r).With("operation", "sum")
+		setLogOperation(r, "sum")
+		var req SumRequest
+		if err := decodeRequest(r, &req); err != nil {
+			handleDecodeError(logger, w, r, err)
+			return
+		}
+
+		result, err := cachedCall(r, cacheKey("sum", floatOperands(req...)...), func() (float64, error) { return Sum(req) })
+		recordCall(r, "sum", floatOperands(req...), result, err)
+		if err != nil {
+			writeError(logger, w, err)
+			return
+		}
+		writeResponse(logger, w, http.StatusOK, Response{Result: result})
+	}
+}
+
+func handleOperation(logger *slog.Logger, w http.ResponseWriter, r *http.Request, req any, op binaryOperation) {
+	logger = requestLogger(logger, r).With("operation", op.name)
+	setLogOperation(r, op.name)
+
 	// Decode the request body
-	var req Request
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
-		logger.Error("Invalid request payload", "error", err)
+	body, err := readBody(r)
+	if err != nil {
+		handleDecodeError(logger, w, r, err)
+		return
+	}
+	body, legacy := legacyOperands(body, req)
+	if legacy {
+		w.Header().Set("Deprecation", "true")
+		logger.Warn("Deprecated operand names a and b were used")
+	}
+	decimalMode, err := isDecimalMode(r, body)
+	if err != nil {
+		handleDecodeError(logger, w, r, err)
+		return
+	}
+	if decimalMode {
+		handleDecimalOperation(logger, w, r, body, decimalBody(req), op)
+		return
+	}
+	if err := decodeBody(body, req); err != nil {
+		handleDecodeError(logger, w, r, err)
 		return
 	}
 
 	// Perform the operation and send the response
-	defer func() {
-		if rec := recover(); rec != nil {
-			logger.Error("Recovered from panic", "error", rec)
-			http.Error(w, "Invalid operation: "+rec.(string), http.StatusBadRequest)
+	a, b := binaryOperands(req)
+	result, err := cachedCall(r, cacheKey(op.name, a, b), func() (float64, error) { return applyOperation(op.float, a, b) })
+	recordCall(r, op.name, floatOperands(a, b), result, err)
+	if err != nil {
+		writeError(logger, w, err)
+		return
+	}
+	writeResponse(logger, w, http.StatusOK, Response{Result: result})
+}
+
+// legacyOperands renames the operands a and b, which the API used before it
+// followed api-spec.yaml, to the names of req's fields, reporting whether it
+// did. The old names are deprecated, and only accepted when none of the new
+// ones are used.
+func legacyOperands(body []byte, req any) ([]byte, bool) {
+	first, second := "number1", "number2"
+	if _, ok := req.(*DivideRequest); ok {
+		first, second = "dividend", "divisor"
+	}
+
+	var object map[string]json.RawMessage
+	if json.Unmarshal(body, &object) != nil {
+		return body, false
+	}
+	_, hasA := object["a"]
+	_, hasB := object["b"]
+	_, hasFirst := object[first]
+	_, hasSecond := object[second]
+	if !hasA && !hasB || hasFirst || hasSecond {
+		return body, false
+	}
+
+	for old, name := range map[string]string{"a": first, "b": second} {
+		if value, ok := object[old]; ok {
+			object[name] = value
+			delete(object, old)
 		}
-	}()
+	}
+	renamed, err := json.Marshal(object)
+	if err != nil {
+		return body, false
+	}
+	return renamed, true
+}
+
+// handleDecodeError reports a request body that could not be read or
+// decoded.
+func handleDecodeError(logger *slog.Logger, w http.ResponseWriter, r *http.Request, err error) {
+	opErr := asOperationError(err)
+	setLogError(r, opErr.Code)
+	logger.Error("Invalid request payload", "code", opErr.Code, "fields", opErr.Fields)
+	writeResponse(logger, w, opErr.Status, opErr.response())
+}
+
+func writeError(logger *slog.Logger, w http.ResponseWriter, err error) {
+	opErr := asOperationError(err)
+	logger.Error("Operation failed", "code", opErr.Code, "error", err)
+	writeResponse(logger, w, opErr.Status, opErr.response())
+}
+
+func writeResponse(logger *slog.Logger, w http.ResponseWriter, status int, resp Response) {
+	writeJSON(logger, w, status, resp)
+}
 
-	result := operation(req.A, req.B)
-	resp := Response{Result: result}
+func writeJSON(logger *slog.Logger, w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
 		logger.Error("Failed to encode response", "error", err)
 	}
 }
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"cloudprojects/calculator-backend-api/buildinfo"
+
+	"golang.org/x/exp/slog"
+)
+
+// readinessTimeout bounds how long a single readiness check may take.
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck reports whether a dependency of the server is ready.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthResponse is the body returned by /healthz and /readyz. Checks maps
+// each readiness check to "ok" or its error.
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// HealthHandler reports that the process is alive and serving requests.
+func HealthHandler(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(logger, w, http.StatusOK, HealthResponse{Status: "ok"})
+	}
+}
+
+// ReadinessHandler runs every check and responds 503 if any of them fails,
+// so that load balancers stop routing traffic to the instance.
+func ReadinessHandler(logger *slog.Logger, checks ...ReadinessCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		resp := HealthResponse{Status: "ready", Checks: make(map[string]string, len(checks))}
+		status := http.StatusOK
+		for _, check := range checks {
+			if err := check.Check(ctx); err != nil {
+				requestLogger(logger, r).Warn("Readiness check failed", "check", check.Name, "error", err)
+				resp.Checks[check.Name] = err.Error()
+				resp.Status = "not ready"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+			resp.Checks[check.Name] = "ok"
+		}
+		writeJSON(logger, w, status, resp)
+	}
+}
+
+// VersionHandler reports the build and start time of the server.
+func VersionHandler(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(logger, w, http.StatusOK, buildinfo.Read())
+	}
+}
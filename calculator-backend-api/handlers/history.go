@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"cloudprojects/calculator-backend-api/expr"
+	"cloudprojects/calculator-backend-api/history"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// HistoryResponse is returned by GET /history.
+type HistoryResponse struct {
+	Entries []history.Entry `json:"entries"`
+	Total   int             `json:"total"`
+	Offset  int             `json:"offset"`
+	Limit   int             `json:"limit"`
+}
+
+// DeleteHistoryResponse is returned by DELETE /history.
+type DeleteHistoryResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+type callRecordKey struct{}
+
+// callRecord collects the details of the calculation performed while serving
+// a request, for HistoryMiddleware to persist once the handler returns.
+type callRecord struct {
+	operation string
+	operands  []any
+	result    any
+	err       string
+	precision string
+}
+
+// recordCall notes the calculation performed for r in the access log and,
+// when the request passed through HistoryMiddleware, in the history.
+func recordCall(r *http.Request, operation string, operands []any, result any, err error) {
+	setLogOperation(r, operation)
+	if err != nil {
+		setLogError(r, errorCode(err))
+	}
+
+	rec, ok := r.Context().Value(callRecordKey{}).(*callRecord)
+	if !ok {
+		return
+	}
+	rec.operation = operation
+	rec.operands = operands
+	if err != nil {
+		rec.err = string(errorCode(err))
+		return
+	}
+	rec.result = result
+}
+
+// recordPrecision notes the precision mode of the calculation recorded for r.
+func recordPrecision(r *http.Request, precision string) {
+	if rec, ok := r.Context().Value(callRecordKey{}).(*callRecord); ok {
+		rec.precision = precision
+	}
+}
+
+// errorCode returns the code reported to clients for err.
+func errorCode(err error) ErrorCode {
+	var syntaxErr *expr.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return CodeSyntaxError
+	}
+	return asOperationError(err).Code
+}
+
+// HistoryMiddleware records every calculation performed by next in store.
+func HistoryMiddleware(next http.Handler, store *history.Store, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &callRecord{}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callRecordKey{}, rec)))
+
+		if rec.operation == "" {
+			return
+		}
+		entry := newHistoryEntry(rec.operation, rec.operands, rec.result, rec.err)
+		entry.Timestamp = start.UTC()
+		entry.RequestID = RequestIDFromContext(r.Context())
+		entry.Caller = caller(r)
+		entry.Precision = rec.precision
+		if _, err := store.Append(entry); err != nil {
+			requestLogger(logger, r).Error("Failed to record calculation", "operation", rec.operation, "error", err)
+		}
+	})
+}
+
+// NewHistoryEntry returns the history entry of a calculation made outside of
+// HTTP, such as a gRPC call, with long operand lists and results shortened
+// like those of HTTP requests. The caller sets its timestamp, request ID and
+// caller.
+func NewHistoryEntry(operation string, operands []any, result any, err error) history.Entry {
+	if err != nil {
+		return newHistoryEntry(operation, operands, nil, string(errorCode(err)))
+	}
+	return newHistoryEntry(operation, operands, result, "")
+}
+
+func newHistoryEntry(operation string, operands []any, result any, code string) history.Entry {
+	recordedOperands, operandsTruncated := truncateRecorded(operands)
+	recordedResult, resultTruncated := truncateRecorded(result)
+	return history.Entry{
+		Operation: operation,
+		Operands:  recordedOperands.([]any),
+		Result:    recordedResult,
+		Error:     code,
+		Truncated: operandsTruncated || resultTruncated,
+	}
+}
+
+// maxRecordedItems is the most items of each list, or of each row of a
+// matrix, that are recorded in the history.
+const maxRecordedItems = 10
+
+// truncateRecorded shortens the lists in v, at any depth, to
+// maxRecordedItems items, reporting whether any were cut.
+func truncateRecorded(v any) (any, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return v, false
+	}
+	items := make([]any, min(rv.Len(), maxRecordedItems))
+	truncated := len(items) < rv.Len()
+	for i := range items {
+		item, cut := truncateRecorded(rv.Index(i).Interface())
+		items[i] = item
+		truncated = truncated || cut
+	}
+	return items, truncated
+}
+
+// caller identifies who made r: the authenticated subject when there is one,
+// otherwise the remote host.
+func caller(r *http.Request) string {
+	if id, ok := IdentityFromContext(r.Context()); ok {
+		return id.Subject
+	}
+	return remoteHost(r)
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// HistoryHandler serves GET /history, which pages through recorded
+// calculations, and DELETE /history?before=<RFC 3339 time>, which removes
+// older entries.
+func HistoryHandler(logger *slog.Logger, store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(logger, r)
+		switch r.Method {
+		case http.MethodGet:
+			queryHistory(logger, w, r, store)
+		case http.MethodDelete:
+			deleteHistory(logger, w, r, store)
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			writeError(logger, w, ErrMethodNotAllowed)
+		}
+	}
+}
+
+func queryHistory(logger *slog.Logger, w http.ResponseWriter, r *http.Request, store *history.Store) {
+	q := r.URL.Query()
+	filter := history.Filter{Operation: q.Get("operation"), Limit: defaultHistoryLimit}
+
+	var err error
+	if filter.Offset, err = intParam(q.Get("offset"), 0); err != nil || filter.Offset < 0 {
+		handleDecodeError(logger, w, r, invalidPayload(FieldError{Field: "offset", Reason: "must be a non-negative integer"}))
+		return
+	}
+	if filter.Limit, err = intParam(q.Get("limit"), defaultHistoryLimit); err != nil || filter.Limit < 1 || filter.Limit > maxHistoryLimit {
+		handleDecodeError(logger, w, r, invalidPayload(FieldError{Field: "limit", Reason: "must be an integer between 1 and " + strconv.Itoa(maxHistoryLimit)}))
+		return
+	}
+	if filter.From, err = timeParam(q.Get("from")); err != nil {
+		handleDecodeError(logger, w, r, invalidPayload(FieldError{Field: "from", Reason: "must be an RFC 3339 time"}))
+		return
+	}
+	if filter.To, err = timeParam(q.Get("to")); err != nil {
+		handleDecodeError(logger, w, r, invalidPayload(FieldError{Field: "to", Reason: "must be an RFC 3339 time"}))
+		return
+	}
+
+	entries, total, err := store.Query(filter)
+	if err != nil {
+		logger.Error("Failed to query history", "error", err)
+		writeError(logger, w, ErrInternal)
+		return
+	}
+	writeJSON(logger, w, http.StatusOK, HistoryResponse{Entries: entries, Total: total, Offset: filter.Offset, Limit: filter.Limit})
+}
+
+func deleteHistory(logger *slog.Logger, w http.ResponseWriter, r *http.Request, store *history.Store) {
+	before, err := timeParam(r.URL.Query().Get("before"))
+	if err != nil || before.IsZero() {
+		handleDecodeError(logger, w, r, invalidPayload(FieldError{Field: "before", Reason: "is required and must be an RFC 3339 time"}))
+		return
+	}
+
+	deleted, err := store.DeleteBefore(before)
+	if err != nil {
+		logger.Error("Failed to delete history", "error", err)
+		writeError(logger, w, ErrInternal)
+		return
+	}
+	logger.Info("Deleted history", "before", before, "deleted", deleted)
+	writeJSON(logger, w, http.StatusOK, DeleteHistoryResponse{Deleted: deleted})
+}
+
+func intParam(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func timeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloudprojects/calculator-backend-api/api"
+	"cloudprojects/calculator-backend-api/cache"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	IdempotencyKeyHeader     = api.IdempotencyKeyHeader
+	IdempotentReplayedHeader = api.IdempotentReplayedHeader
+
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyStore remembers the first response to each idempotency key for
+// a fixed time, so that retried requests are answered without running again.
+type IdempotencyStore struct {
+	responses *cache.LRU[string, storedResponse]
+
+	mu       sync.Mutex
+	inFlight map[string]struct{}
+}
+
+// storedResponse is a response recorded for replay, along with the
+// fingerprint of the request that produced it.
+type storedResponse struct {
+	request     [sha256.Size]byte
+	status      int
+	contentType string
+	body        []byte
+}
+
+// NewIdempotencyStore returns a store of up to maxKeys responses, each kept
+// for ttl.
+func NewIdempotencyStore(maxKeys int, ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		responses: cache.New[string, storedResponse](maxKeys, ttl),
+		inFlight:  make(map[string]struct{}),
+	}
+}
+
+// begin claims key for a request, failing if another request holds it.
+func (s *IdempotencyStore) begin(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.inFlight[key]; ok {
+		return false
+	}
+	s.inFlight[key] = struct{}{}
+	return true
+}
+
+func (s *IdempotencyStore) end(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, key)
+}
+
+// IdempotencyMiddleware replays the stored response to POST requests that
+// repeat an Idempotency-Key, instead of serving them again. Keys are scoped
+// to the caller and path, and reusing one for a different request is an
+// error.
+// Server errors are not stored, so those requests can be retried.
+func IdempotencyMiddleware(next http.Handler, store *IdempotencyStore, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger := requestLogger(logger, r)
+		if len(key) > maxIdempotencyKeyLength {
+			handleDecodeError(logger, w, r, invalidPayload(FieldError{Field: IdempotencyKeyHeader, Reason: "must be at most 255 characters"}))
+			return
+		}
+
+		body, err := readAll(r)
+		if err != nil {
+			handleDecodeError(logger, w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		request := fingerprint(r, body)
+		key = caller(r) + " " + r.URL.Path + " " + key
+
+		if !store.begin(key) {
+			setLogError(r, ErrIdempotencyInUse.Code)
+			writeError(logger, w, ErrIdempotencyInUse)
+			return
+		}
+		defer store.end(key)
+
+		if stored, ok := store.responses.Get(key); ok {
+			if stored.request != request {
+				setLogError(r, ErrIdempotencyReuse.Code)
+				writeError(logger, w, ErrIdempotencyReuse)
+				return
+			}
+			logger.Info("Replaying idempotent response", "status", stored.status)
+			setLogCache(r, "replay")
+			w.Header().Set("Content-Type", stored.contentType)
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(stored.status)
+			w.Write(stored.body)
+			return
+		}
+
+		rec := &recordingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status < http.StatusInternalServerError {
+			store.responses.Add(key, storedResponse{
+				request:     request,
+				status:      rec.status,
+				contentType: w.Header().Get("Content-Type"),
+				body:        rec.body.Bytes(),
+			})
+		}
+	})
+}
+
+// fingerprint hashes what makes requests to the same path different: the
+// query string, which can hold the operands, the body and how it is encoded,
+// and the precision mode the header asks for.
+func fingerprint(r *http.Request, body []byte) [sha256.Size]byte {
+	h := sha256.New()
+	io.WriteString(h, r.URL.RawQuery+"\n")
+	io.WriteString(h, r.Header.Get("Content-Type")+"\n")
+	io.WriteString(h, r.Header.Get(PrecisionHeader)+"\n")
+	h.Write(body)
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// recordingResponseWriter copies the response it writes for later replay.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingResponseWriter) WriteHeader(code int) {
+	rw.status = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingResponseWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
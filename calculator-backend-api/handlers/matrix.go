@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"cloudprojects/calculator-backend-api/matrix"
+
+	"golang.org/x/exp/slog"
+)
+
+// MatrixRequest is the body accepted by /matrix/transpose,
+// /matrix/determinant and /matrix/inverse. Matrices are arrays of rows.
+type MatrixRequest struct {
+	Matrix [][]float64 `json:"matrix"`
+}
+
+// MatrixPairRequest is the body accepted by /matrix/multiply.
+type MatrixPairRequest struct {
+	A [][]float64 `json:"a"`
+	B [][]float64 `json:"b"`
+}
+
+// DotRequest is the body accepted by /matrix/dot.
+type DotRequest struct {
+	A []float64 `json:"a"`
+	B []float64 `json:"b"`
+}
+
+// SolveRequest is the body accepted by /matrix/solve, which solves a·x = b.
+type SolveRequest struct {
+	A [][]float64 `json:"a"`
+	B []float64   `json:"b"`
+}
+
+// MatrixResponse holds a matrix result.
+type MatrixResponse struct {
+	Result [][]float64 `json:"result"`
+}
+
+// VectorResponse holds a vector result.
+type VectorResponse struct {
+	Result []float64 `json:"result"`
+}
+
+// matrixOperationSpec declares a matrix operation. request and response are
+// values of its body types. run validates the decoded request, whose
+// matrices may have at most maxElements elements, and computes the result.
+type matrixOperationSpec struct {
+	name     string
+	summary  string
+	request  any
+	response any
+	run      func(req any, maxElements int) (any, error)
+}
+
+// matrixOperations is the registry of matrix operations, each served at
+// /matrix/<name>.
+var matrixOperations = []matrixOperationSpec{
+	{name: "dot", summary: "Dot product of vectors a and b",
+		request: DotRequest{}, response: Response{}, run: dot},
+	{name: "multiply", summary: "Matrix product a·b",
+		request: MatrixPairRequest{}, response: MatrixResponse{}, run: matrixMultiply},
+	{name: "transpose", summary: "Transpose of a matrix",
+		request: MatrixRequest{}, response: MatrixResponse{}, run: transpose},
+	{name: "determinant", summary: "Determinant of a square matrix",
+		request: MatrixRequest{}, response: Response{}, run: determinant},
+	{name: "inverse", summary: "Inverse of a square matrix",
+		request: MatrixRequest{}, response: MatrixResponse{}, run: inverse},
+	{name: "solve", summary: "Solve the linear system a·x = b for x",
+		request: SolveRequest{}, response: VectorResponse{}, run: solve},
+}
+
+// lookupMatrixOperation finds a matrix operation by name.
+func lookupMatrixOperation(name string) (*matrixOperationSpec, bool) {
+	i := slices.IndexFunc(matrixOperations, func(spec matrixOperationSpec) bool { return spec.name == name })
+	if i < 0 {
+		return nil, false
+	}
+	return &matrixOperations[i], true
+}
+
+// MatrixOperationNames returns the names of the matrix operations, in
+// registration order.
+func MatrixOperationNames() []string {
+	names := make([]string, len(matrixOperations))
+	for i, spec := range matrixOperations {
+		names[i] = spec.name
+	}
+	return names
+}
+
+func dot(req any, maxElements int) (any, error) {
+	r := req.(*DotRequest)
+	if err := checkVector("a", r.A, maxElements); err != nil {
+		return nil, err
+	}
+	if err := checkVector("b", r.B, maxElements); err != nil {
+		return nil, err
+	}
+	if len(r.B) != len(r.A) {
+		return nil, dimensionMismatch("b", "must have %d elements like a, has %d", len(r.A), len(r.B))
+	}
+	result := matrix.Dot(r.A, r.B)
+	if err := checkResult(result); err != nil {
+		return nil, err
+	}
+	return Response{Result: result}, nil
+}
+
+func matrixMultiply(req any, maxElements int) (any, error) {
+	r := req.(*MatrixPairRequest)
+	m, k, err := checkMatrix("a", r.A, maxElements)
+	if err != nil {
+		return nil, err
+	}
+	rows, n, err := checkMatrix("b", r.B, maxElements)
+	if err != nil {
+		return nil, err
+	}
+	if rows != k {
+		return nil, dimensionMismatch("b", "must have %d rows to match the columns of a, has %d", k, rows)
+	}
+	if m*n > maxElements {
+		return nil, matrixTooLarge("", "the %dx%d product would have more than %d elements", m, n, maxElements)
+	}
+	return matrixResult(matrix.Multiply(r.A, r.B))
+}
+
+func transpose(req any, maxElements int) (any, error) {
+	r := req.(*MatrixRequest)
+	if _, _, err := checkMatrix("matrix", r.Matrix, maxElements); err != nil {
+		return nil, err
+	}
+	return MatrixResponse{Result: matrix.Transpose(r.Matrix)}, nil
+}
+
+func determinant(req any, maxElements int) (any, error) {
+	r := req.(*MatrixRequest)
+	if err := checkSquare("matrix", r.Matrix, maxElements); err != nil {
+		return nil, err
+	}
+	result := matrix.Determinant(r.Matrix)
+	if err := checkResult(result); err != nil {
+		return nil, err
+	}
+	return Response{Result: result + 0}, nil
+}
+
+func inverse(req any, maxElements int) (any, error) {
+	r := req.(*MatrixRequest)
+	if err := checkSquare("matrix", r.Matrix, maxElements); err != nil {
+		return nil, err
+	}
+	result, err := matrix.Inverse(r.Matrix)
+	if err != nil {
+		return nil, matrixError(err)
+	}
+	return matrixResult(result)
+}
+
+func solve(req any, maxElements int) (any, error) {
+	r := req.(*SolveRequest)
+	if err := checkSquare("a", r.A, maxElements); err != nil {
+		return nil, err
+	}
+	if err := checkVector("b", r.B, maxElements); err != nil {
+		return nil, err
+	}
+	if len(r.B) != len(r.A) {
+		return nil, dimensionMismatch("b", "must have %d elements to match the rows of a, has %d", len(r.A), len(r.B))
+	}
+	x, err := matrix.Solve(r.A, r.B)
+	if err != nil {
+		return nil, matrixError(err)
+	}
+	if err := checkVectorResult(x); err != nil {
+		return nil, err
+	}
+	return VectorResponse{Result: x}, nil
+}
+
+// checkVector validates that v, found in field, is a non-empty vector of at
+// most maxElements numbers.
+func checkVector(field string, v []float64, maxElements int) error {
+	switch {
+	case len(v) == 0:
+		return invalidPayload(FieldError{Field: field, Reason: "must not be empty"})
+	case len(v) > maxElements:
+		return matrixTooLarge(field, "must have at most %d elements, has %d", maxElements, len(v))
+	}
+	return nil
+}
+
+// checkMatrix validates that m, found in field, is a non-empty rectangular
+// matrix of at most maxElements numbers, and returns its shape.
+func checkMatrix(field string, m [][]float64, maxElements int) (rows, cols int, err error) {
+	if len(m) == 0 || len(m[0]) == 0 {
+		return 0, 0, invalidPayload(FieldError{Field: field, Reason: "must be a non-empty array of non-empty rows"})
+	}
+	rows, cols = len(m), len(m[0])
+	for i, row := range m {
+		if len(row) != cols {
+			return 0, 0, invalidPayload(FieldError{
+				Field:  fmt.Sprintf("%s[%d]", field, i),
+				Reason: fmt.Sprintf("must have %d columns like %s[0], has %d", cols, field, len(row)),
+			})
+		}
+	}
+	if rows*cols > maxElements {
+		return 0, 0, matrixTooLarge(field, "must have at most %d elements, has %d (%dx%d)", maxElements, rows*cols, rows, cols)
+	}
+	return rows, cols, nil
+}
+
+// checkSquare validates that m, found in field, is a square matrix.
+func checkSquare(field string, m [][]float64, maxElements int) error {
+	rows, cols, err := checkMatrix(field, m, maxElements)
+	if err != nil {
+		return err
+	}
+	if rows != cols {
+		return dimensionMismatch(field, "must be square, is %dx%d", rows, cols)
+	}
+	return nil
+}
+
+func dimensionMismatch(field, format string, args ...any) *OperationError {
+	return &OperationError{
+		Code:    CodeDimensionMismatch,
+		Status:  http.StatusBadRequest,
+		Message: "operands have incompatible shapes",
+		Fields:  []FieldError{{Field: field, Reason: fmt.Sprintf(format, args...)}},
+	}
+}
+
+func matrixTooLarge(field, format string, args ...any) *OperationError {
+	return &OperationError{
+		Code:    CodeMatrixTooLarge,
+		Status:  http.StatusRequestEntityTooLarge,
+		Message: ErrMatrixTooLarge.Message,
+		Fields:  []FieldError{{Field: field, Reason: fmt.Sprintf(format, args...)}},
+	}
+}
+
+// matrixError maps errors from the matrix package.
+func matrixError(err error) error {
+	if errors.Is(err, matrix.ErrSingular) {
+		return ErrSingularMatrix
+	}
+	return err
+}
+
+// matrixResult checks that every element of m is finite.
+func matrixResult(m [][]float64) (MatrixResponse, error) {
+	for _, row := range m {
+		if err := checkVectorResult(row); err != nil {
+			return MatrixResponse{}, err
+		}
+	}
+	return MatrixResponse{Result: m}, nil
+}
+
+// checkVectorResult checks that every element of v is finite, and turns
+// negative zeros positive.
+func checkVectorResult(v []float64) error {
+	for i, x := range v {
+		if err := checkResult(x); err != nil {
+			return err
+		}
+		v[i] = x + 0
+	}
+	return nil
+}
+
+// MatrixHandler serves the matrix operation named op, with matrices of at
+// most maxElements elements. It panics if op is not registered. Results are
+// not cached, since they can be large.
+func MatrixHandler(logger *slog.Logger, op string, maxElements int) http.HandlerFunc {
+	spec, ok := lookupMatrixOperation(op)
+	if !ok {
+		panic("handlers: unknown matrix operation " + op)
+	}
+	name := "matrix/" + spec.name
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(logger, r).With("operation", name)
+		setLogOperation(r, name)
+
+		req := reflect.New(reflect.TypeOf(spec.request))
+		if err := decodeRequest(r, req.Interface()); err != nil {
+			handleDecodeError(logger, w, r, err)
+			return
+		}
+
+		var recorded []any
+		for i := 0; i < req.Elem().NumField(); i++ {
+			recorded = append(recorded, req.Elem().Field(i).Interface())
+		}
+		resp, err := spec.run(req.Interface(), maxElements)
+		var result any
+		switch resp := resp.(type) {
+		case Response:
+			result = resp.Result
+		case MatrixResponse:
+			result = resp.Result
+		case VectorResponse:
+			result = resp.Result
+		}
+		recordCall(r, name, recorded, result, err)
+		if err != nil {
+			writeError(logger, w, err)
+			return
+		}
+		writeJSON(logger, w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus collectors for the calculator service.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	inFlight prometheus.Gauge
+}
+
+// NewMetrics creates the service's collectors in a dedicated registry, along
+// with the standard Go runtime and process collectors.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "calculator_requests_total",
+			Help: "Requests served, by operation and HTTP status or gRPC code.",
+		}, []string{"operation", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "calculator_request_duration_seconds",
+			Help:    "Request latency, by operation and HTTP status or gRPC code.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"operation", "status"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "calculator_errors_total",
+			Help: "Failed requests, by operation and error code.",
+		}, []string{"operation", "code"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "calculator_requests_in_flight",
+			Help: "Requests currently being served.",
+		}),
+	}
+	m.registry.MustRegister(
+		m.requests, m.duration, m.errors, m.inFlight,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Start counts a request as in flight until the returned function records it
+// as finished, with its operation, status and error code, if any. The status
+// of an HTTP request is its status code, that of a gRPC call its code name.
+func (m *Metrics) Start() func(operation, status string, code ErrorCode) {
+	m.inFlight.Inc()
+	start := time.Now()
+	return func(operation, status string, code ErrorCode) {
+		m.inFlight.Dec()
+		m.requests.WithLabelValues(operation, status).Inc()
+		m.duration.WithLabelValues(operation, status).Observe(time.Since(start).Seconds())
+		if code != "" {
+			m.errors.WithLabelValues(operation, string(code)).Inc()
+		}
+	}
+}
+
+// MetricsMiddleware records the request count, latency and error code of
+// every request to route. The operation label is the calculation performed,
+// or the route without its leading slash for other handlers.
+func MetricsMiddleware(next http.Handler, m *Metrics, route string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		finish := m.Start()
+		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		rl, r := withRequestLog(r)
+
+		next.ServeHTTP(lrw, r)
+
+		operation := rl.operation
+		if operation == "" {
+			operation = strings.TrimPrefix(route, "/")
+		}
+		finish(operation, strconv.Itoa(lrw.statusCode), ErrorCode(rl.errorCode))
+	})
+}
@@ -1,34 +1,267 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
 	"time"
 
+	"cloudprojects/calculator-backend-api/api"
 	"golang.org/x/exp/slog"
 )
 
+// RequestIDHeader carries the request ID in both requests and responses.
+const RequestIDHeader = api.RequestIDHeader
+
+// maxRequestIDLength bounds the length of a client supplied request ID.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying a request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID attached by RequestIDMiddleware.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDMiddleware attaches a request ID to the request context and echoes
+// it in the response. A well-formed X-Request-ID from the client is reused,
+// otherwise a random ID is generated.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !ValidRequestID(id) {
+			id = NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(ContextWithRequestID(r.Context(), id)))
+	})
+}
+
+// ValidRequestID reports whether a client supplied request ID is short and
+// made of printable ASCII characters.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RecoveryMiddleware turns a panic in any handler into a logged 500 with the
+// standard JSON error body, naming the request ID so the failure can be
+// found in the logs.
+func RecoveryMiddleware(next http.Handler, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoveryResponseWriter{ResponseWriter: w}
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			logger := requestLogger(logger, r)
+			logger.Error("Recovered from panic", "path", r.URL.Path, "method", r.Method, "panic", rec, "stack", string(debug.Stack()))
+			if rw.wroteHeader {
+				// Too late to send an error, abort the response instead.
+				panic(http.ErrAbortHandler)
+			}
+			resp := ErrInternal.response()
+			if id := RequestIDFromContext(r.Context()); id != "" {
+				resp.Message = fmt.Sprintf("%s, request ID %s", resp.Message, id)
+			}
+			writeResponse(logger, w, ErrInternal.Status, resp)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// recoveryResponseWriter notes whether the response has been started.
+type recoveryResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (rw *recoveryResponseWriter) WriteHeader(code int) {
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recoveryResponseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.ResponseWriter.Write(b)
+}
+
+// Hijack lets handlers take over the connection, e.g. for WebSockets.
+func (rw *recoveryResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rw.wroteHeader = true
+	return http.NewResponseController(rw.ResponseWriter).Hijack()
+}
+
+func (rw *recoveryResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// MethodNotAllowedHandler responds 405 with an Allow header listing methods,
+// for requests to a route with any other method. GET implies HEAD.
+func MethodNotAllowedHandler(logger *slog.Logger, methods ...string) http.HandlerFunc {
+	allow := slices.Clone(methods)
+	if slices.Contains(allow, http.MethodGet) && !slices.Contains(allow, http.MethodHead) {
+		allow = append(allow, http.MethodHead)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(logger, r)
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		resp := ErrMethodNotAllowed.response()
+		resp.Message = fmt.Sprintf("method %s is not allowed, allowed methods are %s", r.Method, strings.Join(allow, ", "))
+		logger.Error("Method not allowed", "path", r.URL.Path, "method", r.Method)
+		writeResponse(logger, w, ErrMethodNotAllowed.Status, resp)
+	}
+}
+
+// NotFoundHandler responds 404 to requests for paths no route serves.
+func NotFoundHandler(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(logger, r)
+		resp := ErrNotFound.response()
+		resp.Message = fmt.Sprintf("no route matches %s", r.URL.Path)
+		logger.Error("Route not found", "path", r.URL.Path, "method", r.Method)
+		writeResponse(logger, w, ErrNotFound.Status, resp)
+	}
+}
+
+// requestLogger returns logger annotated with the request's ID.
+func requestLogger(logger *slog.Logger, r *http.Request) *slog.Logger {
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		return logger.With("request_id", id)
+	}
+	return logger
+}
+
+type requestLogKey struct{}
+
+// requestLog collects details that handlers learn while serving a request,
+// for LoggingMiddleware and MetricsMiddleware to report.
+type requestLog struct {
+	operation string
+	errorCode string
+	// cache is "hit" or "miss" when the result cache was consulted, or
+	// "replay" for a replayed idempotent response.
+	cache string
+}
+
+// withRequestLog returns the requestLog attached to r, attaching a new one
+// if there is none.
+func withRequestLog(r *http.Request) (*requestLog, *http.Request) {
+	if rl, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+		return rl, r
+	}
+	rl := &requestLog{}
+	return rl, r.WithContext(context.WithValue(r.Context(), requestLogKey{}, rl))
+}
+
+// setLogOperation names the operation performed for r.
+func setLogOperation(r *http.Request, operation string) {
+	if rl, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+		rl.operation = operation
+	}
+}
+
+// setLogCache notes whether r was served from the result cache.
+func setLogCache(r *http.Request, status string) {
+	if rl, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+		rl.cache = status
+	}
+}
+
+// setLogError notes the error code r failed with.
+func setLogError(r *http.Request, code ErrorCode) {
+	if rl, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+		rl.errorCode = string(code)
+	}
+}
+
 func LoggingMiddleware(next http.Handler, logger *slog.Logger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		rl, r := withRequestLog(r)
 
 		next.ServeHTTP(lrw, r)
 
 		duration := time.Since(start)
-		logger.Info("Request completed",
+		var caller string
+		if id, ok := IdentityFromContext(r.Context()); ok {
+			caller = id.Subject
+		}
+		requestLogger(logger, r).Info("Request completed",
 			"path", r.URL.Path,
 			"method", r.Method,
 			"status", lrw.statusCode,
-			"duration", duration)
+			"duration", duration,
+			"bytes", lrw.bytes,
+			"operation", rl.operation,
+			"error_code", rl.errorCode,
+			"cache", rl.cache,
+			"caller", caller,
+			"remote_ip", remoteHost(r),
+			"forwarded_for", r.Header.Get("X-Forwarded-For"),
+			"user_agent", r.UserAgent())
 	})
 }
 
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int
 }
 
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.statusCode = code
 	lrw.ResponseWriter.WriteHeader(code)
 }
+
+func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
+	n, err := lrw.ResponseWriter.Write(b)
+	lrw.bytes += n
+	return n, err
+}
+
+// Hijack lets handlers take over the connection, which is logged as
+// switching protocols.
+func (lrw *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(lrw.ResponseWriter).Hijack()
+	if err == nil {
+		lrw.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
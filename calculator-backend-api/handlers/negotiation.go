@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/exp/slog"
+)
+
+// Media types that calculation requests and responses may be encoded in.
+const (
+	mediaJSON    = "application/json"
+	mediaForm    = "application/x-www-form-urlencoded"
+	mediaCBOR    = "application/cbor"
+	mediaXML     = "application/xml"
+	mediaTextXML = "text/xml"
+	mediaText    = "text/plain"
+)
+
+// RequestMediaTypes and ResponseMediaTypes list the encodings calculation
+// endpoints accept and serve, besides JSON.
+var (
+	RequestMediaTypes  = []string{mediaForm, mediaCBOR}
+	ResponseMediaTypes = []string{mediaCBOR, mediaXML, mediaText}
+)
+
+// responseMediaTypes are the media types responses can be encoded in, in
+// order of preference when the Accept header allows several equally.
+var responseMediaTypes = []string{mediaJSON, mediaCBOR, mediaXML, mediaText, mediaTextXML}
+
+var (
+	cborDecMode, _ = cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]any(nil)),
+		DupMapKey:      cbor.DupMapKeyEnforcedAPF,
+	}.DecMode()
+	cborEncMode, _ = cbor.EncOptions{Sort: cbor.SortCanonical}.EncMode()
+)
+
+// requestJSON reads the request body according to its Content-Type and
+// returns it as JSON, so that every encoding is validated the same way.
+// A request without a body or Content-Type is read from its query string.
+func requestJSON(r *http.Request) ([]byte, error) {
+	contentType := r.Header.Get("Content-Type")
+	body, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "" && len(body) == 0 && r.URL.RawQuery != "" {
+		return formJSON(r.URL.Query())
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+	switch mediaType {
+	case mediaJSON:
+		return body, nil
+	case mediaForm:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, invalidPayload(FieldError{Reason: "malformed form body"})
+		}
+		return formJSON(values)
+	case mediaCBOR:
+		return cborJSON(body)
+	}
+	return nil, ErrUnsupportedMediaType
+}
+
+// formJSON converts form values into a JSON object. Values that are JSON
+// numbers become numbers and others strings. Repeated keys, and keys ending
+// in "[]", become arrays.
+func formJSON(values url.Values) ([]byte, error) {
+	object := make(map[string]any, len(values))
+	for key, vals := range values {
+		name, isArray := strings.CutSuffix(key, "[]")
+		items, _ := object[name].([]any)
+		for _, v := range vals {
+			items = append(items, formValue(v))
+		}
+		if isArray || len(items) > 1 {
+			object[name] = items
+		} else {
+			object[name] = items[0]
+		}
+	}
+	return json.Marshal(object)
+}
+
+func formValue(v string) any {
+	if v != "" && (v[0] == '-' || v[0] >= '0' && v[0] <= '9') && json.Valid([]byte(v)) {
+		return json.RawMessage(v)
+	}
+	return v
+}
+
+// cborJSON converts a CBOR body into JSON.
+func cborJSON(body []byte) ([]byte, error) {
+	if len(body) == 0 {
+		return body, nil
+	}
+	var v any
+	if err := cborDecMode.Unmarshal(body, &v); err != nil {
+		return nil, invalidPayload(FieldError{Reason: "malformed CBOR: " + strings.TrimPrefix(err.Error(), "cbor: ")})
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, invalidPayload(FieldError{Reason: "CBOR body has values that cannot be represented in JSON"})
+	}
+	return out, nil
+}
+
+// NegotiationMiddleware encodes the JSON responses of next in the media type
+// preferred by the Accept header: JSON, CBOR, XML or plain text. Requests
+// that accept none of these get 406.
+func NegotiationMiddleware(next http.Handler, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		mediaType, ok := negotiate(r.Header.Get("Accept"))
+		if !ok {
+			resp := ErrNotAcceptable.response()
+			writeResponse(requestLogger(logger, r), w, ErrNotAcceptable.Status, resp)
+			return
+		}
+		if mediaType == mediaJSON {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf := &bufferedResponseWriter{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+		body := buf.body.Bytes()
+		if ct, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type")); ct == mediaJSON {
+			encoded, err := encodeAs(mediaType, body)
+			if err != nil {
+				requestLogger(logger, r).Error("Failed to encode response", "media_type", mediaType, "error", err)
+			} else {
+				body = encoded
+				w.Header().Set("Content-Type", mediaType)
+				if mediaType == mediaText || mediaType == mediaTextXML {
+					w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+				}
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(buf.status)
+		w.Write(body)
+	})
+}
+
+// bufferedResponseWriter holds a response back so that it can be re-encoded.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (bw *bufferedResponseWriter) Header() http.Header { return bw.header }
+
+func (bw *bufferedResponseWriter) WriteHeader(code int) { bw.status = code }
+
+func (bw *bufferedResponseWriter) Write(b []byte) (int, error) { return bw.body.Write(b) }
+
+// negotiate picks the response media type with the highest quality in
+// accept. An empty Accept header means JSON.
+func negotiate(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return mediaJSON, true
+	}
+
+	type mediaRange struct {
+		typ, subtype string
+		q            float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		typ, subtype, _ := strings.Cut(mediaType, "/")
+		ranges = append(ranges, mediaRange{typ, subtype, q})
+	}
+
+	best, bestQ := "", 0.0
+	for _, candidate := range responseMediaTypes {
+		typ, subtype, _ := strings.Cut(candidate, "/")
+		// The most specific range that matches decides the quality.
+		q, specificity := 0.0, -1
+		for _, mr := range ranges {
+			s := -1
+			switch {
+			case mr.typ == typ && mr.subtype == subtype:
+				s = 2
+			case mr.typ == typ && mr.subtype == "*":
+				s = 1
+			case mr.typ == "*" && mr.subtype == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = mr.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = candidate, q
+		}
+	}
+	return best, best != ""
+}
+
+// encodeAs re-encodes a JSON body in mediaType.
+func encodeAs(mediaType string, body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	switch mediaType {
+	case mediaCBOR:
+		return cborEncMode.Marshal(cborValue(v))
+	case mediaXML, mediaTextXML:
+		var buf bytes.Buffer
+		buf.WriteString(xml.Header)
+		enc := xml.NewEncoder(&buf)
+		if err := encodeXML(enc, "response", v); err != nil {
+			return nil, err
+		}
+		if err := enc.Flush(); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	case mediaText:
+		return encodeText(v), nil
+	}
+	return nil, fmt.Errorf("unsupported media type %s", mediaType)
+}
+
+// cborValue converts decoded JSON numbers into integers where they are
+// whole, and floats otherwise.
+func cborValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, item := range v {
+			v[key] = cborValue(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = cborValue(item)
+		}
+	}
+	return v
+}
+
+// encodeXML writes v as an element called name. Objects become child
+// elements named by their keys, and array items <item> elements.
+func encodeXML(enc *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			if err := encodeXML(enc, key, v[key]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := encodeXML(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// encodeText renders a result as just its value, an error as its code and
+// message followed by its field errors, and anything else as one
+// "key: value" line per field.
+func encodeText(v any) []byte {
+	var buf bytes.Buffer
+	object, _ := v.(map[string]any)
+	code, isError := object["error"].(string)
+	switch {
+	case isError:
+		fmt.Fprintf(&buf, "%s: %s\n", code, object["message"])
+		fields, _ := object["fields"].([]any)
+		for _, f := range fields {
+			field, _ := f.(map[string]any)
+			fmt.Fprintln(&buf, strings.TrimSpace(fmt.Sprintf("%s %s", field["field"], field["reason"])))
+		}
+	case object != nil && len(object) == 1 && object["result"] != nil:
+		writeTextResult(&buf, object["result"])
+	default:
+		writeTextLines(&buf, "", v)
+	}
+	return buf.Bytes()
+}
+
+// writeTextResult writes a scalar result on one line, a vector as one line of
+// space separated values, and a matrix as one such line per row.
+func writeTextResult(buf *bytes.Buffer, result any) {
+	items, ok := result.([]any)
+	if !ok {
+		fmt.Fprintln(buf, result)
+		return
+	}
+	if len(items) > 0 {
+		if _, isMatrix := items[0].([]any); isMatrix {
+			for _, row := range items {
+				writeTextResult(buf, row)
+			}
+			return
+		}
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i] = fmt.Sprint(item)
+	}
+	fmt.Fprintln(buf, strings.Join(values, " "))
+}
+
+func writeTextLines(buf *bytes.Buffer, path string, v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			writeTextLines(buf, strings.TrimPrefix(path+"."+key, "."), v[key])
+		}
+	case []any:
+		for i, item := range v {
+			writeTextLines(buf, fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	default:
+		fmt.Fprintf(buf, "%s: %v\n", path, v)
+	}
+}
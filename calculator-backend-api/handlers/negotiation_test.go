@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func mustCBOR(t *testing.T, v any) string {
+	t.Helper()
+	data, err := cbor.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRequestJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		query       string
+		body        string
+		want        string
+		code        ErrorCode
+	}{
+		{"json", "application/json", "", `{"number1":1}`, `{"number1":1}`, ""},
+		{"json with charset", "application/json; charset=utf-8", "", `{"number1":1}`, `{"number1":1}`, ""},
+		{"form", "application/x-www-form-urlencoded", "", "number1=1.5&number2=-2", `{"number1":1.5,"number2":-2}`, ""},
+		{"form strings", "application/x-www-form-urlencoded", "", "expression=1%2B2&precision=", `{"expression":"1+2","precision":""}`, ""},
+		{"form exponent", "application/x-www-form-urlencoded", "", "number1=1e3", `{"number1":1e3}`, ""},
+		{"form not quite a number", "application/x-www-form-urlencoded", "", "number1=1x", `{"number1":"1x"}`, ""},
+		{"form repeated key", "application/x-www-form-urlencoded", "", "numbers=1&numbers=2", `{"numbers":[1,2]}`, ""},
+		{"form array key", "application/x-www-form-urlencoded", "", "numbers[]=1", `{"numbers":[1]}`, ""},
+		{"form malformed", "application/x-www-form-urlencoded", "", "number1=%zz", "", CodeInvalidPayload},
+		{"cbor", "application/cbor", "", mustCBOR(t, map[string]any{"number1": 1.5, "number2": 2}), `{"number1":1.5,"number2":2}`, ""},
+		{"cbor array", "application/cbor", "", mustCBOR(t, map[string]any{"numbers": []int{1, 2}}), `{"numbers":[1,2]}`, ""},
+		{"cbor empty", "application/cbor", "", "", "", ""},
+		{"cbor malformed", "application/cbor", "", "\xa1\x67number", "", CodeInvalidPayload},
+		{"cbor duplicate key", "application/cbor", "", "\xa2\x61a\x01\x61a\x02", "", CodeInvalidPayload},
+		{"cbor non-string key", "application/cbor", "", "\xa1\x01\x02", "", CodeInvalidPayload},
+		{"query string", "", "number1=1&number2=2", "", `{"number1":1,"number2":2}`, ""},
+		{"query string ignored with a body", "application/json", "number1=1", `{"number1":2}`, `{"number1":2}`, ""},
+		{"no content type", "", "", `{"number1":1}`, "", CodeUnsupportedType},
+		{"unsupported", "application/xml", "", "<number1>1</number1>", "", CodeUnsupportedType},
+		{"malformed content type", "application/", "", "{}", "", CodeUnsupportedType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/add"
+			if tt.query != "" {
+				target += "?" + tt.query
+			}
+			r := httptest.NewRequest("POST", target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			got, err := requestJSON(r)
+			if tt.code != "" {
+				if err == nil || asOperationError(err).Code != tt.code {
+					t.Fatalf("got %s, %v, want %s", got, err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", mediaJSON},
+		{"*/*", mediaJSON},
+		{"application/json", mediaJSON},
+		{"application/cbor", mediaCBOR},
+		{"application/xml", mediaXML},
+		{"text/xml", mediaTextXML},
+		{"text/plain", mediaText},
+		{"text/*", mediaText},
+		{"application/*", mediaJSON},
+		{"application/json;q=0.5, application/cbor", mediaCBOR},
+		{"text/plain, */*;q=0.1", mediaText},
+		{"*/*, application/json;q=0", mediaCBOR},
+		{"text/html, application/xml;q=0.9", mediaXML},
+		{"application/json;q=bad, text/plain", mediaText},
+		{"text/html", ""},
+		{"application/json;q=0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			got, ok := negotiate(tt.accept)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("got %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeAs(t *testing.T) {
+	tests := []struct {
+		name      string
+		mediaType string
+		body      string
+		want      string
+	}{
+		{"text result", mediaText, `{"result":3}`, "3\n"},
+		{"text vector", mediaText, `{"result":[1,2.5]}`, "1 2.5\n"},
+		{"text matrix", mediaText, `{"result":[[1,2],[3,4]]}`, "1 2\n3 4\n"},
+		{"text error", mediaText, `{"error":"invalid_payload","message":"bad","fields":[{"field":"number1","reason":"is required"}]}`,
+			"invalid_payload: bad\nnumber1 is required\n"},
+		{"text object", mediaText, `{"result":1,"steps":[{"op":"add"}]}`, "result: 1\nsteps[0].op: add\n"},
+		{"xml", mediaXML, `{"result":[1,2],"note":null}`,
+			`<?xml version="1.0" encoding="UTF-8"?>` + "\n<response><note></note><result><item>1</item><item>2</item></result></response>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeAs(tt.mediaType, []byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("cbor", func(t *testing.T) {
+		got, err := encodeAs(mediaCBOR, []byte(`{"result":3,"values":[0.5,-2]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded struct {
+			Result any   `cbor:"result"`
+			Values []any `cbor:"values"`
+		}
+		if err := cbor.Unmarshal(got, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		// Whole numbers are encoded as integers, others as floats.
+		if decoded.Result != uint64(3) || decoded.Values[0] != 0.5 || decoded.Values[1] != int64(-2) {
+			t.Errorf("got %#v", decoded)
+		}
+	})
+}
+
+func TestNegotiationMiddleware(t *testing.T) {
+	handler := NegotiationMiddleware(AddHandler(discard), discard)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		accept      string
+		status      int
+		wantType    string
+		wantBody    string
+	}{
+		{"json", "application/json", `{"number1":1,"number2":2}`, "", http.StatusOK, "application/json", `{"result":3}`},
+		{"form to text", "application/x-www-form-urlencoded", "number1=1&number2=2", "text/plain", http.StatusOK, "text/plain; charset=utf-8", "3\n"},
+		{"cbor to cbor", "application/cbor", mustCBOR(t, map[string]int{"number1": 1, "number2": 2}), "application/cbor", http.StatusOK, "application/cbor", "\xa1\x66result\x03"},
+		{"error as text", "application/json", `{"number1":1}`, "text/plain", http.StatusBadRequest, "text/plain; charset=utf-8", "invalid_payload: "},
+		{"not acceptable", "application/json", `{"number1":1,"number2":2}`, "text/html", http.StatusNotAcceptable, "application/json", `"error":"not_acceptable"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/add", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+			if rec.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("got Content-Type %q, want %q", got, tt.wantType)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("got body %q, want it to contain %q", rec.Body, tt.wantBody)
+			}
+			if rec.Header().Get("Vary") != "Accept" {
+				t.Errorf("got Vary %q, want Accept", rec.Header().Get("Vary"))
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+
+	"golang.org/x/exp/slog"
+)
+
+// variadic is the arity of operations that reduce an array of numbers.
+const variadic = -1
+
+// UnaryRequest is the body accepted by single-operand operations such as
+// /ln and /sin.
+type UnaryRequest struct {
+	Number float64 `json:"number"`
+}
+
+// ArrayRequest is the body accepted by statistical reductions such as /mean.
+type ArrayRequest struct {
+	Numbers []float64 `json:"numbers"`
+}
+
+// PercentileRequest is the body accepted by /percentile, where P is the
+// percentile to compute.
+type PercentileRequest struct {
+	Numbers []float64 `json:"numbers"`
+	P       float64   `json:"p"`
+}
+
+// rule validates a single operand.
+type rule struct {
+	valid  func(x float64) bool
+	reason string
+}
+
+var (
+	positive      = rule{func(x float64) bool { return x > 0 }, "must be greater than 0"}
+	nonZero       = rule{func(x float64) bool { return x != 0 }, "must not be 0"}
+	notOne        = rule{func(x float64) bool { return x != 1 }, "must not be 1"}
+	unitInterval  = rule{func(x float64) bool { return x >= -1 && x <= 1 }, "must be between -1 and 1"}
+	percentRange  = rule{func(x float64) bool { return x >= 0 && x <= 100 }, "must be between 0 and 100"}
+	anyNumber     []rule
+	positiveBase  = []rule{positive, notOne}
+	positiveValue = []rule{positive}
+)
+
+// operationSpec declares a registered operation: how many operands it takes,
+// the values it accepts and how it is computed.
+type operationSpec struct {
+	name    string
+	summary string
+	// arity is the number of operands, or variadic for an array.
+	arity int
+	// minOperands is the fewest numbers a variadic operation accepts.
+	minOperands int
+	// rules[i] validates operand i. A variadic operation applies rules[0]
+	// to every number.
+	rules [][]rule
+	// param names an extra body field required by a variadic operation,
+	// validated by paramRules.
+	param      string
+	paramRules []rule
+	// check validates combinations of operands the rules cannot express.
+	check func(x []float64) []FieldError
+	apply func(x []float64, param float64) (float64, error)
+}
+
+// operations is the registry of scientific and statistical operations, each
+// served at /<name>.
+var operations = []operationSpec{
+	{name: "power", summary: "Raise number1 to the power of number2",
+		arity: 2, rules: [][]rule{anyNumber, anyNumber}, check: checkPower,
+		apply: func(x []float64, _ float64) (float64, error) { return math.Pow(x[0], x[1]), nil }},
+	{name: "modulo", summary: "Remainder of number1 divided by number2",
+		arity: 2, rules: [][]rule{anyNumber, anyNumber}, apply: modulo},
+	{name: "root", summary: "The number2-th root of number1",
+		arity: 2, rules: [][]rule{anyNumber, {nonZero}}, check: checkRoot, apply: root},
+	{name: "log", summary: "Logarithm of number1 to base number2",
+		arity: 2, rules: [][]rule{positiveValue, positiveBase},
+		apply: func(x []float64, _ float64) (float64, error) { return math.Log(x[0]) / math.Log(x[1]), nil }},
+	{name: "ln", summary: "Natural logarithm",
+		arity: 1, rules: [][]rule{positiveValue}, apply: unary(math.Log)},
+	{name: "log10", summary: "Base 10 logarithm",
+		arity: 1, rules: [][]rule{positiveValue}, apply: unary(math.Log10)},
+	{name: "sin", summary: "Sine of an angle in radians",
+		arity: 1, rules: [][]rule{anyNumber}, apply: unary(math.Sin)},
+	{name: "cos", summary: "Cosine of an angle in radians",
+		arity: 1, rules: [][]rule{anyNumber}, apply: unary(math.Cos)},
+	{name: "tan", summary: "Tangent of an angle in radians",
+		arity: 1, rules: [][]rule{anyNumber}, apply: unary(math.Tan)},
+	{name: "asin", summary: "Arcsine, in radians",
+		arity: 1, rules: [][]rule{{unitInterval}}, apply: unary(math.Asin)},
+	{name: "acos", summary: "Arccosine, in radians",
+		arity: 1, rules: [][]rule{{unitInterval}}, apply: unary(math.Acos)},
+	{name: "atan", summary: "Arctangent, in radians",
+		arity: 1, rules: [][]rule{anyNumber}, apply: unary(math.Atan)},
+	{name: "mean", summary: "Arithmetic mean",
+		arity: variadic, minOperands: 1, rules: [][]rule{anyNumber}, apply: mean},
+	{name: "median", summary: "Median",
+		arity: variadic, minOperands: 1, rules: [][]rule{anyNumber},
+		apply: func(x []float64, _ float64) (float64, error) { return percentile(x, 50) }},
+	{name: "stddev", summary: "Sample standard deviation",
+		arity: variadic, minOperands: 2, rules: [][]rule{anyNumber}, apply: stddev},
+	{name: "percentile", summary: "Percentile p, interpolated between ranks",
+		arity: variadic, minOperands: 1, rules: [][]rule{anyNumber},
+		param: "p", paramRules: []rule{percentRange},
+		apply: func(x []float64, p float64) (float64, error) { return percentile(x, p) }},
+}
+
+// lookupOperation finds a registered operation by name.
+func lookupOperation(name string) (*operationSpec, bool) {
+	i := slices.IndexFunc(operations, func(spec operationSpec) bool { return spec.name == name })
+	if i < 0 {
+		return nil, false
+	}
+	return &operations[i], true
+}
+
+// OperationNames returns the names of the registered operations, in
+// registration order.
+func OperationNames() []string {
+	names := make([]string, len(operations))
+	for i, spec := range operations {
+		names[i] = spec.name
+	}
+	return names
+}
+
+func unary(f func(float64) float64) func(x []float64, _ float64) (float64, error) {
+	return func(x []float64, _ float64) (float64, error) { return f(x[0]), nil }
+}
+
+func checkPower(x []float64) []FieldError {
+	switch {
+	case x[0] < 0 && x[1] != math.Trunc(x[1]):
+		return []FieldError{{Field: "number2", Reason: "must be an integer when number1 is negative"}}
+	case x[0] == 0 && x[1] < 0:
+		return []FieldError{{Field: "number2", Reason: "must not be negative when number1 is 0"}}
+	}
+	return nil
+}
+
+func modulo(x []float64, _ float64) (float64, error) {
+	if x[1] == 0 {
+		return 0, ErrDivisionByZero
+	}
+	return math.Mod(x[0], x[1]), nil
+}
+
+func checkRoot(x []float64) []FieldError {
+	if x[0] < 0 && (x[1] != math.Trunc(x[1]) || math.Mod(x[1], 2) == 0) {
+		return []FieldError{{Field: "number1", Reason: "must not be negative for an even or fractional root"}}
+	}
+	return nil
+}
+
+// root returns the number2-th root of number1. Square and cube roots use the
+// exact library functions; odd roots of negative numbers are real, so they are
+// taken of the magnitude and negated.
+func root(x []float64, _ float64) (float64, error) {
+	switch {
+	case x[1] == 2:
+		return math.Sqrt(x[0]), nil
+	case x[1] == 3:
+		return math.Cbrt(x[0]), nil
+	case x[0] < 0:
+		return -math.Pow(-x[0], 1/x[1]), nil
+	}
+	return math.Pow(x[0], 1/x[1]), nil
+}
+
+// mean uses a running mean so that large operands do not overflow a sum.
+func mean(x []float64, _ float64) (float64, error) {
+	var m float64
+	for i, n := range x {
+		m += (n - m) / float64(i+1)
+	}
+	return m, nil
+}
+
+// stddev returns the sample standard deviation, using Welford's algorithm.
+func stddev(x []float64, _ float64) (float64, error) {
+	var m, m2 float64
+	for i, n := range x {
+		delta := n - m
+		m += delta / float64(i+1)
+		m2 += delta * (n - m)
+	}
+	return math.Sqrt(m2 / float64(len(x)-1)), nil
+}
+
+// percentile returns the p-th percentile, interpolating linearly between the
+// closest ranks.
+func percentile(x []float64, p float64) (float64, error) {
+	sorted := slices.Clone(x)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower)), nil
+}
+
+// operandNames returns the request field names of an operation's operands.
+func (spec *operationSpec) operandNames(n int) []string {
+	switch spec.arity {
+	case 1:
+		return []string{"number"}
+	case 2:
+		return []string{"number1", "number2"}
+	}
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("numbers[%d]", i)
+	}
+	return names
+}
+
+// validate checks the operands and parameter against the operation's rules.
+func (spec *operationSpec) validate(x []float64, param *float64) error {
+	if spec.arity == variadic && len(x) < spec.minOperands {
+		reason := "must not be empty"
+		if spec.minOperands > 1 {
+			reason = fmt.Sprintf("must contain at least %d numbers", spec.minOperands)
+		}
+		return invalidPayload(FieldError{Field: "numbers", Reason: reason})
+	}
+	switch {
+	case spec.param == "" && param != nil:
+		return invalidPayload(FieldError{Field: "p", Reason: "is not a known field"})
+	case spec.param != "" && param == nil:
+		return invalidPayload(FieldError{Field: spec.param, Reason: "is required"})
+	}
+	if err := checkOperands(x...); err != nil {
+		return err
+	}
+
+	var fields []FieldError
+	for i, name := range spec.operandNames(len(x)) {
+		rules := spec.rules[0]
+		if spec.arity != variadic {
+			rules = spec.rules[i]
+		}
+		fields = append(fields, checkRules(name, x[i], rules)...)
+	}
+	if param != nil {
+		if err := checkOperands(*param); err != nil {
+			return err
+		}
+		fields = append(fields, checkRules(spec.param, *param, spec.paramRules)...)
+	}
+	if len(fields) == 0 && spec.check != nil {
+		fields = spec.check(x)
+	}
+	if len(fields) > 0 {
+		return domainError(fields...)
+	}
+	return nil
+}
+
+func checkRules(field string, x float64, rules []rule) []FieldError {
+	for _, r := range rules {
+		if !r.valid(x) {
+			return []FieldError{{Field: field, Reason: r.reason}}
+		}
+	}
+	return nil
+}
+
+// run validates the operands, applies the operation and validates the result.
+func (spec *operationSpec) run(x []float64, param *float64) (float64, error) {
+	if err := spec.validate(x, param); err != nil {
+		return 0, err
+	}
+	var p float64
+	if param != nil {
+		p = *param
+	}
+	result, err := spec.apply(x, p)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkResult(result); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+// Apply runs the registered operation named op on operands, as the HTTP
+// endpoints do. param is the extra parameter of operations that take one,
+// such as the percentile, and must be nil otherwise.
+func Apply(op string, operands []float64, param *float64) (float64, error) {
+	spec, ok := lookupOperation(op)
+	if !ok {
+		return 0, ErrUnknownOp
+	}
+	if spec.arity != variadic && len(operands) != spec.arity {
+		return 0, invalidPayload(FieldError{Reason: fmt.Sprintf("%s does not take %d operands", op, len(operands))})
+	}
+	return spec.run(operands, param)
+}
+
+// OperationHandler serves the registered operation named op, decoding the
+// request shape that matches its arity. It panics if op is not registered.
+func OperationHandler(logger *slog.Logger, op string) http.HandlerFunc {
+	spec, ok := lookupOperation(op)
+	if !ok {
+		panic("handlers: unknown operation " + op)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(logger, r).With("operation", spec.name)
+		setLogOperation(r, spec.name)
+
+		var operands []float64
+		var param *float64
+		var err error
+		switch spec.arity {
+		case 1:
+			var req UnaryRequest
+			err = decodeRequest(r, &req)
+			operands = []float64{req.Number}
+		case 2:
+			var req Request
+			err = decodeRequest(r, &req)
+			operands = []float64{req.A, req.B}
+		default:
+			if spec.param == "" {
+				var req ArrayRequest
+				err = decodeRequest(r, &req)
+				operands = req.Numbers
+			} else {
+				var req PercentileRequest
+				err = decodeRequest(r, &req)
+				operands, param = req.Numbers, &req.P
+			}
+		}
+		if err != nil {
+			handleDecodeError(logger, w, r, err)
+			return
+		}
+
+		recorded := floatOperands(operands...)
+		if param != nil {
+			recorded = append(recorded, *param)
+		}
+		result, err := cachedCall(r, cacheKey(spec.name, recorded...), func() (float64, error) { return spec.run(operands, param) })
+		recordCall(r, spec.name, recorded, result, err)
+		if err != nil {
+			writeError(logger, w, err)
+			return
+		}
+		writeResponse(logger, w, http.StatusOK, Response{Result: result})
+	}
+}
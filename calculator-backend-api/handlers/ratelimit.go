@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// sweepInterval is how often idle buckets are discarded.
+const sweepInterval = time.Minute
+
+// RateLimitConfig configures a RateLimiter. Rate is the sustained number of
+// requests per second each client may make and Burst the number it may make
+// at once. TrustedProxies lists the addresses or CIDR ranges whose
+// X-Forwarded-For header is believed.
+type RateLimitConfig struct {
+	Rate           float64
+	Burst          int
+	TrustedProxies []string
+}
+
+// RateLimiter is a per-client token bucket limiter. Clients are identified
+// by their authenticated identity, falling back to their IP address.
+type RateLimiter struct {
+	rate    float64
+	burst   float64
+	trusted []netip.Prefix
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(cfg RateLimitConfig) (*RateLimiter, error) {
+	if cfg.Rate <= 0 || cfg.Burst < 1 {
+		return nil, fmt.Errorf("rate limit must be positive and burst at least 1, got rate %v and burst %d", cfg.Rate, cfg.Burst)
+	}
+	l := &RateLimiter{
+		rate:    cfg.Rate,
+		burst:   float64(cfg.Burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", proxy, err)
+		}
+		l.trusted = append(l.trusted, prefix)
+	}
+	l.lastSweep = l.now()
+	return l, nil
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// rateLimitResult describes a client's bucket after a request was counted.
+type rateLimitResult struct {
+	allowed   bool
+	remaining int
+	// reset is the time until the bucket is full again and retryAfter the
+	// time until the next request would be allowed.
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// allow takes a token from key's bucket if one is available.
+func (l *RateLimiter) allow(key string) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	res := rateLimitResult{allowed: b.tokens >= 1}
+	if res.allowed {
+		b.tokens--
+	} else {
+		res.retryAfter = l.duration(1 - b.tokens)
+	}
+	res.remaining = int(b.tokens)
+	res.reset = l.duration(l.burst - b.tokens)
+	return res
+}
+
+// duration returns the time needed to refill the given number of tokens.
+func (l *RateLimiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep discards buckets that have refilled completely, which behave the
+// same as a missing bucket.
+func (l *RateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Allow counts a request made outside of HTTP, such as a gRPC call, against
+// the identity in ctx, or against ip without one. It reports whether the
+// request is allowed and, if not, when the next one would be.
+func (l *RateLimiter) Allow(ctx context.Context, ip string) (bool, time.Duration) {
+	res := l.allow(clientKey(ctx, ip))
+	return res.allowed, res.retryAfter
+}
+
+// clientKey identifies the client a request is counted against.
+func clientKey(ctx context.Context, ip string) string {
+	if id, ok := IdentityFromContext(ctx); ok {
+		return "identity:" + id.Subject
+	}
+	return "ip:" + ip
+}
+
+// clientIP returns the address of the client. When the request came through
+// a trusted proxy, X-Forwarded-For is walked from the right and the first
+// untrusted address is the client.
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	remote := remoteHost(r)
+	addr, err := netip.ParseAddr(remote)
+	if err != nil || !l.isTrusted(addr) {
+		return remote
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		if !l.isTrusted(hop) {
+			return hop.String()
+		}
+		addr = hop
+	}
+	return addr.String()
+}
+
+func (l *RateLimiter) isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range l.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimitMiddleware rejects clients that exceed the limiter's rate with a
+// 429, and reports the client's quota in RateLimit-* headers.
+func RateLimitMiddleware(next http.Handler, limiter *RateLimiter, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := clientKey(r.Context(), limiter.clientIP(r))
+		res := limiter.allow(key)
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(int(limiter.burst)))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.reset)))
+
+		if !res.allowed {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.retryAfter)))
+			setLogError(r, CodeRateLimited)
+			requestLogger(logger, r).Warn("Rate limit exceeded", "client", key, "path", r.URL.Path, "method", r.Method)
+			writeResponse(logger, w, ErrRateLimited.Status, ErrRateLimited.response())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeClock is a time source for a RateLimiter that only moves when told to.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func testRateLimiter(t *testing.T, cfg RateLimitConfig) (*RateLimiter, *fakeClock) {
+	t.Helper()
+	l, err := NewRateLimiter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	l.now = clock.now
+	l.lastSweep = clock.now()
+	return l, clock
+}
+
+func TestNewRateLimiterValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  RateLimitConfig
+	}{
+		{"zero rate", RateLimitConfig{Rate: 0, Burst: 1}},
+		{"negative rate", RateLimitConfig{Rate: -1, Burst: 1}},
+		{"zero burst", RateLimitConfig{Rate: 1, Burst: 0}},
+		{"invalid proxy", RateLimitConfig{Rate: 1, Burst: 1, TrustedProxies: []string{"proxy.internal"}}},
+		{"invalid proxy range", RateLimitConfig{Rate: 1, Burst: 1, TrustedProxies: []string{"10.0.0.0/33"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRateLimiter(tt.cfg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, clock := testRateLimiter(t, RateLimitConfig{Rate: 2, Burst: 3})
+
+	// Each step advances the clock, then takes a token from the bucket.
+	tests := []struct {
+		name       string
+		advance    time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{"full bucket", 0, true, 2, 0},
+		{"second", 0, true, 1, 0},
+		{"third", 0, true, 0, 0},
+		{"empty", 0, false, 0, 500 * time.Millisecond},
+		{"half a token later", 250 * time.Millisecond, false, 0, 250 * time.Millisecond},
+		{"refilled one token", 250 * time.Millisecond, true, 0, 0},
+		{"refill is capped at the burst", time.Hour, true, 2, 0},
+	}
+	for _, tt := range tests {
+		clock.advance(tt.advance)
+		res := l.allow("client")
+		if res.allowed != tt.allowed || res.remaining != tt.remaining || res.retryAfter != tt.retryAfter {
+			t.Errorf("%s: got allowed %v, remaining %d, retry after %v; want %v, %d, %v",
+				tt.name, res.allowed, res.remaining, res.retryAfter, tt.allowed, tt.remaining, tt.retryAfter)
+		}
+	}
+
+	if res := l.allow("other client"); !res.allowed || res.remaining != 2 {
+		t.Errorf("clients share a bucket: got %+v", res)
+	}
+}
+
+func TestSweepDiscardsFullBuckets(t *testing.T) {
+	l, clock := testRateLimiter(t, RateLimitConfig{Rate: 1, Burst: 10})
+	l.allow("idle")
+	clock.advance(sweepInterval - time.Second)
+	for i := 0; i < 10; i++ {
+		l.allow("busy")
+	}
+	clock.advance(time.Second)
+	l.allow("trigger")
+
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("the idle client's refilled bucket was kept")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("the busy client's bucket was discarded")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	l, _ := testRateLimiter(t, RateLimitConfig{Rate: 1, Burst: 1, TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"}})
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"direct", "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"untrusted remote ignores the header", "203.0.113.5:1234", []string{"198.51.100.7"}, "203.0.113.5"},
+		{"trusted proxy", "10.0.0.1:1234", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"rightmost untrusted hop wins over a spoofed one", "10.0.0.1:1234", []string{"1.1.1.1, 198.51.100.7"}, "198.51.100.7"},
+		{"chain of trusted proxies", "10.0.0.1:1234", []string{"198.51.100.7, 192.0.2.1, 10.1.2.3"}, "198.51.100.7"},
+		{"repeated headers are joined", "10.0.0.1:1234", []string{"1.1.1.1", "198.51.100.7"}, "198.51.100.7"},
+		{"all hops trusted", "10.0.0.1:1234", []string{"10.0.0.2"}, "10.0.0.2"},
+		{"unparsable hop stops the walk", "10.0.0.1:1234", []string{"198.51.100.7, garbage, 10.0.0.2"}, "10.0.0.2"},
+		{"no header", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"IPv4-mapped trusted proxy", "[::ffff:10.0.0.1]:1234", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"IPv6 client", "10.0.0.1:1234", []string{"2001:db8::1"}, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := l.clientIP(r); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	l, clock := testRateLimiter(t, RateLimitConfig{Rate: 0.5, Burst: 2})
+	handler := RateLimitMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), l, discard)
+
+	serve := func(ctx context.Context) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/add", nil).WithContext(ctx)
+		r.RemoteAddr = "203.0.113.5:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	tests := []struct {
+		status     int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{http.StatusOK, "1", "2", ""},
+		{http.StatusOK, "0", "4", ""},
+		{http.StatusTooManyRequests, "0", "4", "2"},
+	}
+	for i, tt := range tests {
+		rec := serve(context.Background())
+		h := rec.Header()
+		if rec.Code != tt.status || h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Remaining") != tt.remaining ||
+			h.Get("RateLimit-Reset") != tt.reset || h.Get("Retry-After") != tt.retryAfter {
+			t.Errorf("request %d: got %d with limit %q, remaining %q, reset %q, retry after %q; want %d, \"2\", %q, %q, %q",
+				i+1, rec.Code, h.Get("RateLimit-Limit"), h.Get("RateLimit-Remaining"), h.Get("RateLimit-Reset"), h.Get("Retry-After"),
+				tt.status, tt.remaining, tt.reset, tt.retryAfter)
+		}
+	}
+
+	// An authenticated caller has a bucket of their own, even from the same
+	// address.
+	ctx := ContextWithIdentity(context.Background(), Identity{Subject: "alice"})
+	if rec := serve(ctx); rec.Code != http.StatusOK {
+		t.Errorf("authenticated caller: got %d, want 200", rec.Code)
+	}
+
+	clock.advance(2 * time.Second)
+	if rec := serve(context.Background()); rec.Code != http.StatusOK {
+		t.Errorf("after the retry delay: got %d, want 200", rec.Code)
+	}
+}
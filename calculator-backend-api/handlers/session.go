@@ -0,0 +1,423 @@
+package handlers
+
+import (
+	"errors"
+	"maps"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// DefaultRegister is the memory register used when a message names none.
+	DefaultRegister = "M"
+
+	maxSessionMessageBytes = 4096
+	sessionPingPeriod      = 30 * time.Second
+	sessionPongWait        = 2 * sessionPingPeriod
+	maxRegisters           = 32
+	maxUndoDepth           = 100
+)
+
+var registerName = regexp.MustCompile(`^[A-Za-z0-9_]{1,32}$`)
+
+// SessionConfig configures calculator sessions on /ws.
+type SessionConfig struct {
+	// TTL is how long a session survives without messages, whether or not a
+	// client is connected to it.
+	TTL time.Duration
+	// MaxSessions caps the number of live sessions.
+	MaxSessions int
+	// AllowedOrigins lists the browser origins allowed to connect, as for
+	// CORS. "*" allows any origin.
+	AllowedOrigins []string
+}
+
+// SessionRequest is a message sent by the client. Op is one of:
+//
+//   - a binary operation such as add or power, applied to the accumulator
+//     and Value, or the value of Register if Value is omitted
+//   - a single-operand operation such as ln or sin, applied to the
+//     accumulator
+//   - set, which sets the accumulator to Value, and clear, which zeroes it
+//   - m+, m-, mr, ms and mc, which add the accumulator to, subtract it from,
+//     recall, store it in and clear Register
+//   - undo and redo
+type SessionRequest struct {
+	ID       int64    `json:"id,omitempty"`
+	Op       string   `json:"op"`
+	Value    *float64 `json:"value,omitempty"`
+	Register string   `json:"register,omitempty"`
+}
+
+// SessionResponse is sent once when a session is opened, and in reply to
+// every SessionRequest, echoing its ID. It always carries the current state,
+// which is unchanged if the request failed.
+type SessionResponse struct {
+	ID        int64              `json:"id,omitempty"`
+	Session   string             `json:"session,omitempty"`
+	Result    float64            `json:"result"`
+	Registers map[string]float64 `json:"registers"`
+	CanUndo   bool               `json:"can_undo"`
+	CanRedo   bool               `json:"can_redo"`
+	Error     string             `json:"error,omitempty"`
+	Message   string             `json:"message,omitempty"`
+	Fields    []FieldError       `json:"fields,omitempty"`
+}
+
+// SessionManager keeps the calculator sessions served on /ws.
+type SessionManager struct {
+	cfg      SessionConfig
+	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+type session struct {
+	id    string
+	owner string
+
+	// attached and lastActive are guarded by the manager's mutex.
+	attached   bool
+	lastActive time.Time
+
+	// The calculator state is only used by the attached connection.
+	state      sessionState
+	undo, redo []sessionState
+}
+
+type sessionState struct {
+	accumulator float64
+	registers   map[string]float64
+}
+
+func (s sessionState) clone() sessionState {
+	return sessionState{accumulator: s.accumulator, registers: maps.Clone(s.registers)}
+}
+
+// NewSessionManager returns a manager with no sessions.
+func NewSessionManager(cfg SessionConfig, logger *slog.Logger) *SessionManager {
+	m := &SessionManager{cfg: cfg, sessions: make(map[string]*session)}
+	m.upgrader = websocket.Upgrader{
+		CheckOrigin: m.checkOrigin,
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			opErr := ErrForbidden
+			if status != http.StatusForbidden {
+				opErr = &OperationError{Code: CodeInvalidPayload, Status: status, Message: reason.Error()}
+			}
+			setLogError(r, opErr.Code)
+			writeError(requestLogger(logger, r), w, opErr)
+		},
+	}
+	return m
+}
+
+// checkOrigin allows requests without an Origin header, which do not come
+// from browsers, and the configured origins.
+func (m *SessionManager) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || slices.Contains(m.cfg.AllowedOrigins, "*") || slices.Contains(m.cfg.AllowedOrigins, origin)
+}
+
+// open attaches to the session id owned by owner, or creates a new session
+// if id is empty.
+func (m *SessionManager) open(owner, id string) (s *session, created bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.sweep(now)
+
+	if id != "" {
+		s, ok := m.sessions[id]
+		if !ok || s.owner != owner {
+			return nil, false, ErrSessionNotFound
+		}
+		if s.attached {
+			return nil, false, ErrSessionInUse
+		}
+		s.attached = true
+		s.lastActive = now
+		return s, false, nil
+	}
+
+	if len(m.sessions) >= m.cfg.MaxSessions {
+		return nil, false, ErrTooManySessions
+	}
+	s = &session{
+		id:         NewRequestID(),
+		owner:      owner,
+		attached:   true,
+		lastActive: now,
+		state:      sessionState{registers: make(map[string]float64)},
+	}
+	m.sessions[s.id] = s
+	return s, true, nil
+}
+
+// sweep discards detached sessions that have been idle for longer than the
+// TTL. Attached sessions expire through their connection's read deadline.
+func (m *SessionManager) sweep(now time.Time) {
+	for id, s := range m.sessions {
+		if !s.attached && now.Sub(s.lastActive) > m.cfg.TTL {
+			delete(m.sessions, id)
+		}
+	}
+}
+
+func (m *SessionManager) touch(s *session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.lastActive = time.Now()
+}
+
+func (m *SessionManager) detach(s *session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.attached = false
+}
+
+func (m *SessionManager) expire(s *session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, s.id)
+}
+
+// Handler upgrades the request to a WebSocket and serves a session on it:
+// a new one, or the one named by the session query parameter, which lets a
+// client resume its session after reconnecting.
+func (m *SessionManager) Handler(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(logger, r).With("operation", "session")
+		setLogOperation(r, "session")
+
+		s, created, err := m.open(caller(r), r.URL.Query().Get("session"))
+		if err != nil {
+			setLogError(r, asOperationError(err).Code)
+			writeError(logger, w, err)
+			return
+		}
+		defer m.detach(s)
+
+		conn, err := m.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// A session that never reached its client should not count
+			// against the limit until it expires.
+			if created {
+				m.expire(s)
+			}
+			return
+		}
+		defer conn.Close()
+		conn.SetReadLimit(maxSessionMessageBytes)
+		logger = logger.With("session", s.id)
+		logger.Info("Session opened")
+
+		// The read deadline expires the session after the TTL without
+		// messages, or detaches it sooner if the client stops answering pings,
+		// so that it can be resumed from a new connection.
+		lastMessage, lastPong := time.Now(), time.Now()
+		setDeadline := func() {
+			deadline := lastMessage.Add(m.cfg.TTL)
+			if pong := lastPong.Add(sessionPongWait); pong.Before(deadline) {
+				deadline = pong
+			}
+			conn.SetReadDeadline(deadline)
+		}
+		conn.SetPongHandler(func(string) error {
+			lastPong = time.Now()
+			setDeadline()
+			return nil
+		})
+		stopPings := make(chan struct{})
+		defer close(stopPings)
+		go func() {
+			ticker := time.NewTicker(sessionPingPeriod)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second))
+				case <-stopPings:
+					return
+				}
+			}
+		}()
+
+		welcome := s.response(0, nil)
+		welcome.Session = s.id
+		if err := conn.WriteJSON(welcome); err != nil {
+			return
+		}
+
+		for {
+			setDeadline()
+			_, data, err := conn.ReadMessage()
+			var netErr net.Error
+			switch {
+			case errors.As(err, &netErr) && netErr.Timeout() && !time.Now().Before(lastMessage.Add(m.cfg.TTL)):
+				logger.Info("Session expired")
+				m.expire(s)
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session expired"), time.Now().Add(time.Second))
+				return
+			case err != nil:
+				logger.Info("Session detached", "reason", err)
+				return
+			}
+			lastMessage = time.Now()
+			m.touch(s)
+
+			var req SessionRequest
+			if err := decodeBody(data, &req); err != nil {
+				conn.WriteJSON(s.response(0, err))
+				continue
+			}
+			err = s.apply(req)
+			if err != nil {
+				logger.Debug("Session operation failed", "op", req.Op, "error", err)
+			}
+			if err := conn.WriteJSON(s.response(req.ID, err)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *session) response(id int64, err error) SessionResponse {
+	resp := SessionResponse{
+		ID:        id,
+		Result:    s.state.accumulator,
+		Registers: s.state.registers,
+		CanUndo:   len(s.undo) > 0,
+		CanRedo:   len(s.redo) > 0,
+	}
+	if err != nil {
+		opErr := asOperationError(err)
+		resp.Error, resp.Message, resp.Fields = string(opErr.Code), opErr.Message, opErr.Fields
+	}
+	return resp
+}
+
+// apply performs req on the session's state, recording the previous state
+// for undo. A failed request leaves the state unchanged.
+func (s *session) apply(req SessionRequest) error {
+	switch req.Op {
+	case "undo":
+		if len(s.undo) == 0 {
+			return invalidPayload(FieldError{Field: "op", Reason: "there is nothing to undo"})
+		}
+		s.redo = append(s.redo, s.state)
+		s.state, s.undo = s.undo[len(s.undo)-1], s.undo[:len(s.undo)-1]
+		return nil
+	case "redo":
+		if len(s.redo) == 0 {
+			return invalidPayload(FieldError{Field: "op", Reason: "there is nothing to redo"})
+		}
+		s.undo = append(s.undo, s.state)
+		s.state, s.redo = s.redo[len(s.redo)-1], s.redo[:len(s.redo)-1]
+		return nil
+	}
+
+	next := s.state.clone()
+	if err := next.apply(req); err != nil {
+		return err
+	}
+	s.undo = append(s.undo, s.state)
+	if len(s.undo) > maxUndoDepth {
+		s.undo = s.undo[1:]
+	}
+	s.redo = nil
+	s.state = next
+	return nil
+}
+
+func (st *sessionState) apply(req SessionRequest) error {
+	register := req.Register
+	if register == "" {
+		register = DefaultRegister
+	}
+	if !registerName.MatchString(register) {
+		return invalidPayload(FieldError{Field: "register", Reason: "must be 1 to 32 letters, digits or underscores"})
+	}
+
+	switch req.Op {
+	case "set":
+		if req.Value == nil {
+			return invalidPayload(FieldError{Field: "value", Reason: "is required"})
+		}
+		if err := checkOperands(*req.Value); err != nil {
+			return err
+		}
+		st.accumulator = *req.Value
+	case "clear":
+		st.accumulator = 0
+	case "m+", "m-":
+		result := st.registers[register] + st.accumulator
+		if req.Op == "m-" {
+			result = st.registers[register] - st.accumulator
+		}
+		if err := checkResult(result); err != nil {
+			return err
+		}
+		return st.store(register, result)
+	case "ms":
+		return st.store(register, st.accumulator)
+	case "mr":
+		value, ok := st.registers[register]
+		if !ok {
+			return invalidPayload(FieldError{Field: "register", Reason: "is empty"})
+		}
+		st.accumulator = value
+	case "mc":
+		delete(st.registers, register)
+	default:
+		return st.calculate(req, register)
+	}
+	return nil
+}
+
+// calculate applies a calculator operation to the accumulator.
+func (st *sessionState) calculate(req SessionRequest, register string) error {
+	if spec, ok := lookupOperation(req.Op); ok && spec.arity == 1 {
+		result, err := spec.run([]float64{st.accumulator}, nil)
+		if err != nil {
+			return err
+		}
+		st.accumulator = result
+		return nil
+	}
+
+	var operand float64
+	switch {
+	case req.Value != nil:
+		operand = *req.Value
+	case req.Register != "":
+		value, ok := st.registers[register]
+		if !ok {
+			return invalidPayload(FieldError{Field: "register", Reason: "is empty"})
+		}
+		operand = value
+	default:
+		return invalidPayload(FieldError{Field: "value", Reason: "is required"})
+	}
+	result, err := Calculate(req.Op, st.accumulator, operand)
+	if err != nil {
+		return err
+	}
+	st.accumulator = result
+	return nil
+}
+
+func (st *sessionState) store(register string, value float64) error {
+	if _, ok := st.registers[register]; !ok && len(st.registers) >= maxRegisters {
+		return invalidPayload(FieldError{Field: "register", Reason: "exceeds the limit of 32 registers"})
+	}
+	st.registers[register] = value
+	return nil
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func value(v float64) *float64 { return &v }
+
+func newTestSession() *session {
+	return &session{state: sessionState{registers: make(map[string]float64)}}
+}
+
+func TestSessionApply(t *testing.T) {
+	s := newTestSession()
+
+	// The steps run in order against the same session.
+	tests := []struct {
+		req       SessionRequest
+		want      float64
+		registers map[string]float64
+		code      ErrorCode
+		canUndo   bool
+		canRedo   bool
+	}{
+		{SessionRequest{Op: "set", Value: value(2)}, 2, map[string]float64{}, "", true, false},
+		{SessionRequest{Op: "add", Value: value(3)}, 5, map[string]float64{}, "", true, false},
+		{SessionRequest{Op: "ms"}, 5, map[string]float64{"M": 5}, "", true, false},
+		{SessionRequest{Op: "multiply", Register: "M"}, 25, map[string]float64{"M": 5}, "", true, false},
+		{SessionRequest{Op: "m+", Register: "x"}, 25, map[string]float64{"M": 5, "x": 25}, "", true, false},
+		{SessionRequest{Op: "undo"}, 25, map[string]float64{"M": 5}, "", true, true},
+		{SessionRequest{Op: "undo"}, 5, map[string]float64{"M": 5}, "", true, true},
+		{SessionRequest{Op: "redo"}, 25, map[string]float64{"M": 5}, "", true, true},
+		{SessionRequest{Op: "divide", Value: value(0)}, 25, map[string]float64{"M": 5}, CodeDivisionByZero, true, true},
+		{SessionRequest{Op: "subtract", Value: value(20)}, 5, map[string]float64{"M": 5}, "", true, false},
+		{SessionRequest{Op: "redo"}, 5, map[string]float64{"M": 5}, CodeInvalidPayload, true, false},
+		{SessionRequest{Op: "mr", Register: "x"}, 5, map[string]float64{"M": 5}, CodeInvalidPayload, true, false},
+		{SessionRequest{Op: "set", Value: value(100)}, 100, map[string]float64{"M": 5}, "", true, false},
+		{SessionRequest{Op: "log10"}, 2, map[string]float64{"M": 5}, "", true, false},
+		{SessionRequest{Op: "mr"}, 5, map[string]float64{"M": 5}, "", true, false},
+		{SessionRequest{Op: "mc"}, 5, map[string]float64{}, "", true, false},
+		{SessionRequest{Op: "clear"}, 0, map[string]float64{}, "", true, false},
+		{SessionRequest{Op: "set"}, 0, map[string]float64{}, CodeInvalidPayload, true, false},
+		{SessionRequest{Op: "add"}, 0, map[string]float64{}, CodeInvalidPayload, true, false},
+		{SessionRequest{Op: "ms", Register: "bad name"}, 0, map[string]float64{}, CodeInvalidPayload, true, false},
+		{SessionRequest{Op: "frobnicate", Value: value(1)}, 0, map[string]float64{}, CodeUnknownOp, true, false},
+	}
+	for i, tt := range tests {
+		err := s.apply(tt.req)
+		resp := s.response(0, err)
+		if ErrorCode(resp.Error) != tt.code || resp.Result != tt.want || resp.CanUndo != tt.canUndo || resp.CanRedo != tt.canRedo {
+			t.Errorf("step %d (%s): got result %v, error %q, undo %v, redo %v; want %v, %q, %v, %v",
+				i+1, tt.req.Op, resp.Result, resp.Error, resp.CanUndo, resp.CanRedo, tt.want, tt.code, tt.canUndo, tt.canRedo)
+		}
+		if fmt.Sprint(resp.Registers) != fmt.Sprint(tt.registers) {
+			t.Errorf("step %d (%s): got registers %v, want %v", i+1, tt.req.Op, resp.Registers, tt.registers)
+		}
+	}
+}
+
+func TestSessionUndoDepth(t *testing.T) {
+	s := newTestSession()
+	for i := 1; i <= maxUndoDepth+10; i++ {
+		if err := s.apply(SessionRequest{Op: "set", Value: value(float64(i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.undo) != maxUndoDepth {
+		t.Fatalf("got %d undo states, want %d", len(s.undo), maxUndoDepth)
+	}
+
+	undone := 0
+	for s.apply(SessionRequest{Op: "undo"}) == nil {
+		undone++
+	}
+	if undone != maxUndoDepth {
+		t.Errorf("undid %d times, want %d", undone, maxUndoDepth)
+	}
+	// The oldest states were dropped: undoing stops at the state after them.
+	if want := float64(10); s.state.accumulator != want {
+		t.Errorf("got %v after undoing everything, want %v", s.state.accumulator, want)
+	}
+
+	redone := 0
+	for s.apply(SessionRequest{Op: "redo"}) == nil {
+		redone++
+	}
+	if redone != maxUndoDepth || s.state.accumulator != maxUndoDepth+10 {
+		t.Errorf("redid %d times to %v, want %d times to %v", redone, s.state.accumulator, maxUndoDepth, maxUndoDepth+10)
+	}
+}
+
+func TestSessionRegisterLimit(t *testing.T) {
+	s := newTestSession()
+	for i := 0; i < maxRegisters; i++ {
+		if err := s.apply(SessionRequest{Op: "ms", Register: fmt.Sprintf("r%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.apply(SessionRequest{Op: "ms", Register: "one_too_many"}); asOperationError(err).Code != CodeInvalidPayload {
+		t.Errorf("got %v, want %s", err, CodeInvalidPayload)
+	}
+	if err := s.apply(SessionRequest{Op: "m+", Register: "r0"}); err != nil {
+		t.Errorf("updating an existing register at the limit: %v", err)
+	}
+}
+
+func TestSessionManagerOpen(t *testing.T) {
+	m := NewSessionManager(SessionConfig{TTL: time.Minute, MaxSessions: 2}, discard)
+
+	s, created, err := m.open("alice", "")
+	if err != nil || !created {
+		t.Fatalf("got %v, %v, want a new session", created, err)
+	}
+
+	tests := []struct {
+		name  string
+		owner string
+		id    string
+		err   error
+	}{
+		{"attached elsewhere", "alice", s.id, ErrSessionInUse},
+		{"other owner", "bob", s.id, ErrSessionNotFound},
+		{"unknown", "alice", "missing", ErrSessionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := m.open(tt.owner, tt.id); err != tt.err {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	m.detach(s)
+	if resumed, created, err := m.open("alice", s.id); err != nil || created || resumed != s {
+		t.Errorf("resuming: got %v, %v, want the same session", created, err)
+	}
+
+	if _, _, err := m.open("bob", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.open("carol", ""); err != ErrTooManySessions {
+		t.Errorf("got %v, want %v", err, ErrTooManySessions)
+	}
+
+	// Detached sessions are discarded once idle for longer than the TTL.
+	m.detach(s)
+	s.lastActive = time.Now().Add(-2 * time.Minute)
+	if _, _, err := m.open("carol", ""); err != nil {
+		t.Errorf("got %v after the idle session expired", err)
+	}
+	if _, _, err := m.open("alice", s.id); err != ErrSessionNotFound {
+		t.Errorf("got %v for the expired session, want %v", err, ErrSessionNotFound)
+	}
+}
+
+func TestSessionWebSocket(t *testing.T) {
+	m := NewSessionManager(SessionConfig{TTL: time.Minute, MaxSessions: 10}, discard)
+	ts := httptest.NewServer(m.Handler(discard))
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	dial := func(query string, header http.Header) (*websocket.Conn, SessionResponse) {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(url+query, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var welcome SessionResponse
+		if err := conn.ReadJSON(&welcome); err != nil {
+			t.Fatal(err)
+		}
+		return conn, welcome
+	}
+	send := func(conn *websocket.Conn, req SessionRequest) SessionResponse {
+		t.Helper()
+		if err := conn.WriteJSON(req); err != nil {
+			t.Fatal(err)
+		}
+		var resp SessionResponse
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	conn, welcome := dial("", nil)
+	if welcome.Session == "" {
+		t.Fatal("the welcome message does not name the session")
+	}
+	send(conn, SessionRequest{ID: 1, Op: "set", Value: value(6)})
+	if resp := send(conn, SessionRequest{ID: 2, Op: "multiply", Value: value(7)}); resp.ID != 2 || resp.Result != 42 || !resp.CanUndo {
+		t.Errorf("got %+v, want 42 in reply to request 2", resp)
+	}
+	conn.Close()
+
+	// The session can be resumed from a new connection once the server has
+	// noticed the old one is gone.
+	var resumed SessionResponse
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		c, _, err := websocket.DefaultDialer.Dial(url+"?session="+welcome.Session, nil)
+		if err == nil {
+			defer c.Close()
+			if err := c.ReadJSON(&resumed); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("could not resume the session: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if resumed.Result != 42 || !resumed.CanUndo {
+		t.Errorf("resumed session: got %+v, want result 42 with undo", resumed)
+	}
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example"}}); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("cross-origin connection: got %v, want 403", err)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"cloudprojects/calculator-backend-api/units"
+
+	"golang.org/x/exp/slog"
+)
+
+// ConvertUnitsRequest is the body accepted by /convert-units. From and To
+// are unit names or symbols, e.g. "km", "miles" or "°F".
+type ConvertUnitsRequest struct {
+	Value float64 `json:"value"`
+	From  string  `json:"from"`
+	To    string  `json:"to"`
+}
+
+// ConvertUnitsResponse holds the converted value along with the canonical
+// name and symbol of the unit it is in.
+type ConvertUnitsResponse struct {
+	Result float64 `json:"result"`
+	Unit   string  `json:"unit"`
+	Symbol string  `json:"symbol"`
+}
+
+// ConvertUnits converts value between the units named from and to, exactly
+// as the HTTP endpoint does.
+func ConvertUnits(value float64, from, to string) (float64, units.Unit, error) {
+	if err := checkOperands(value); err != nil {
+		return 0, units.Unit{}, err
+	}
+	fromUnit, fromErr := units.Lookup(from)
+	toUnit, toErr := units.Lookup(to)
+	var fields []FieldError
+	if fromErr != nil {
+		fields = append(fields, FieldError{Field: "from", Reason: "is not a known unit"})
+	}
+	if toErr != nil {
+		fields = append(fields, FieldError{Field: "to", Reason: "is not a known unit"})
+	}
+	if len(fields) > 0 {
+		return 0, units.Unit{}, invalidPayload(fields...)
+	}
+
+	result, err := units.Convert(value, fromUnit, toUnit)
+	var incompatible *units.IncompatibleError
+	switch {
+	case errors.As(err, &incompatible):
+		return 0, units.Unit{}, &OperationError{
+			Code:    CodeIncompatibleUnits,
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Fields: []FieldError{{
+				Field:  "to",
+				Reason: fmt.Sprintf("must be a unit of %s, not %s", fromUnit.Dimension, toUnit.Dimension),
+			}},
+		}
+	case errors.Is(err, units.ErrBelowAbsoluteZero):
+		return 0, units.Unit{}, domainError(FieldError{Field: "value", Reason: "must not be below absolute zero"})
+	case err != nil:
+		return 0, units.Unit{}, err
+	}
+	if err := checkResult(result); err != nil {
+		return 0, units.Unit{}, err
+	}
+	return result, toUnit, nil
+}
+
+func ConvertUnitsHandler(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(logger, r).With("operation", "convert-units")
+		setLogOperation(r, "convert-units")
+		var req ConvertUnitsRequest
+		if err := decodeRequest(r, &req); err != nil {
+			handleDecodeError(logger, w, r, err)
+			return
+		}
+
+		resp, err := cachedCall(r, cacheKey("convert-units", req.Value, req.From, req.To), func() (ConvertUnitsResponse, error) {
+			result, unit, err := ConvertUnits(req.Value, req.From, req.To)
+			return ConvertUnitsResponse{Result: result, Unit: unit.Name, Symbol: unit.Symbol}, err
+		})
+		recordCall(r, "convert-units", []any{req.Value, req.From, req.To}, resp.Result, err)
+		if err != nil {
+			writeError(logger, w, err)
+			return
+		}
+		writeJSON(logger, w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"cloudprojects/calculator-backend-api/api"
+)
+
+// FieldError describes a problem with one field of a request.
+type FieldError = api.FieldError
+
+// invalidPayload returns an invalid_payload error listing fields.
+func invalidPayload(fields ...FieldError) *OperationError {
+	return &OperationError{
+		Code:    CodeInvalidPayload,
+		Status:  http.StatusBadRequest,
+		Message: "request payload is invalid",
+		Fields:  fields,
+	}
+}
+
+// BodyLimitMiddleware rejects request bodies larger than maxBytes.
+func BodyLimitMiddleware(next http.Handler, maxBytes int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readBody reads the request body as JSON, whichever supported encoding it
+// was sent in.
+func readBody(r *http.Request) ([]byte, error) {
+	return requestJSON(r)
+}
+
+// readAll reads the request body, reporting bodies over the size limit.
+func readAll(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return nil, &OperationError{
+			Code:    CodePayloadTooLarge,
+			Status:  http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("request body exceeds %d bytes", maxErr.Limit),
+		}
+	}
+	if err != nil {
+		return nil, invalidPayload(FieldError{Reason: "request body could not be read"})
+	}
+	return body, nil
+}
+
+// decodeBody strictly decodes a JSON body into v: unknown fields, trailing
+// data, nulls and missing fields without omitempty are all rejected.
+func decodeBody(body []byte, v any) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return invalidPayload(FieldError{Reason: "request body is empty"})
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return invalidPayload(FieldError{Reason: "unexpected data after the JSON value"})
+	}
+
+	if fields := checkRequired(body, reflect.TypeOf(v), ""); len(fields) > 0 {
+		return invalidPayload(fields...)
+	}
+	return nil
+}
+
+// decodeRequest reads the request body and decodes it into v.
+func decodeRequest(r *http.Request, v any) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	return decodeBody(body, v)
+}
+
+// decodeError converts an encoding/json error into field errors.
+func decodeError(err error) *OperationError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return invalidPayload(FieldError{Reason: fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset)})
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return invalidPayload(FieldError{Reason: "malformed JSON, unexpected end of body"})
+	case errors.As(err, &typeErr):
+		// Numbers too large for a float64 are out of range rather than
+		// malformed.
+		if strings.HasPrefix(typeErr.Value, "number") && isNumberKind(typeErr.Type) {
+			return &OperationError{
+				Code:    CodeOutOfRange,
+				Status:  http.StatusBadRequest,
+				Message: ErrOutOfRange.Message,
+				Fields:  []FieldError{{Field: typeErr.Field, Reason: "is out of range"}},
+			}
+		}
+		return invalidPayload(FieldError{Field: typeErr.Field, Reason: "must be " + jsonTypeName(typeErr.Type)})
+	}
+
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if unquoted, err := strconv.Unquote(name); err == nil {
+			name = unquoted
+		}
+		return invalidPayload(FieldError{Field: name, Reason: "is not a known field"})
+	}
+	return invalidPayload(FieldError{Reason: strings.TrimPrefix(err.Error(), "json: ")})
+}
+
+func isNumberKind(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// jsonTypeName describes the JSON value expected for a Go type.
+func jsonTypeName(t reflect.Type) string {
+	if t == reflect.TypeOf(json.Number("")) {
+		return "a number or numeric string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	}
+	if isNumberKind(t) {
+		return "a number"
+	}
+	return "a " + t.Kind().String()
+}
+
+// checkRequired walks a decoded JSON value alongside the Go type it was
+// decoded into and reports struct fields that are missing or null. Fields
+// tagged omitempty, and pointer fields, are optional.
+func checkRequired(data json.RawMessage, t reflect.Type, path string) []FieldError {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(data, &object) != nil {
+			return nil
+		}
+		return checkStructFields(object, t, path)
+	case reflect.Slice, reflect.Array:
+		var items []json.RawMessage
+		if json.Unmarshal(data, &items) != nil {
+			return nil
+		}
+		var fields []FieldError
+		for i, item := range items {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if isNull(item) && t.Elem().Kind() != reflect.Pointer {
+				fields = append(fields, FieldError{Field: itemPath, Reason: "must not be null"})
+				continue
+			}
+			fields = append(fields, checkRequired(item, t.Elem(), itemPath)...)
+		}
+		return fields
+	}
+	return nil
+}
+
+func checkStructFields(object map[string]json.RawMessage, t reflect.Type, path string) []FieldError {
+	var fields []FieldError
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" {
+			fields = append(fields, checkStructFields(object, f.Type, path)...)
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+
+		optional := strings.Contains(","+opts+",", ",omitempty,") || f.Type.Kind() == reflect.Pointer
+		value, present := object[name]
+		switch {
+		case !present && !optional:
+			fields = append(fields, FieldError{Field: fieldPath, Reason: "is required"})
+		case present && isNull(value) && !optional:
+			fields = append(fields, FieldError{Field: fieldPath, Reason: "must not be null"})
+		case present:
+			fields = append(fields, checkRequired(value, f.Type, fieldPath)...)
+		}
+	}
+	return fields
+}
+
+func isNull(data json.RawMessage) bool {
+	return string(bytes.TrimSpace(data)) == "null"
+}
@@ -0,0 +1,333 @@
+// Package history persists a log of calculations to an append-only file of
+// JSON lines. Only a small index of the entries is kept in memory: queries
+// filter the index and read the matching page of entries back from the file.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry is a single recorded calculation.
+type Entry struct {
+	ID        int64     `json:"id"`
+	Operation string    `json:"operation"`
+	Operands  []any     `json:"operands"`
+	Result    any       `json:"result,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	RequestID string    `json:"request_id,omitempty"`
+	Caller    string    `json:"caller,omitempty"`
+	// Precision is "decimal" for calculations made in decimal precision
+	// mode, whose operands and result are recorded as decimal strings
+	// rather than numbers.
+	Precision string `json:"precision,omitempty"`
+	// Truncated is set when long operand lists or results were shortened
+	// before they were recorded.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Filter selects entries in a Query. Zero values match everything.
+type Filter struct {
+	Operation string
+	From, To  time.Time
+	Offset    int
+	Limit     int
+}
+
+func (f Filter) match(r record) bool {
+	switch {
+	case f.Operation != "" && r.operation != f.Operation:
+		return false
+	case !f.From.IsZero() && r.timestamp.Before(f.From):
+		return false
+	case !f.To.IsZero() && !r.timestamp.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// Retention bounds the history. Entries beyond MaxEntries, oldest first, and
+// entries older than MaxAge are removed. Zero values disable a bound.
+type Retention struct {
+	MaxEntries int
+	MaxAge     time.Duration
+}
+
+// pruneInterval is how often entries are checked against Retention.MaxAge.
+const pruneInterval = time.Minute
+
+// record is the in-memory index of an entry: the fields queries filter on and
+// where the entry is in the file.
+type record struct {
+	operation string
+	timestamp time.Time
+	offset    int64
+	length    int
+}
+
+// Store is a file-backed calculation history. It is safe for concurrent use.
+type Store struct {
+	mu        sync.Mutex
+	path      string
+	retention Retention
+	file      *os.File
+	size      int64
+	records   []record
+	nextID    int64
+	lastPrune time.Time
+	// operations interns operation names, which repeat in most entries.
+	operations map[string]string
+}
+
+// Open loads the history at path, creating the file if it does not exist,
+// and applies retention to it.
+func Open(path string, retention Retention) (*Store, error) {
+	s := &Store{path: path, retention: retention, nextID: 1, operations: make(map[string]string)}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.file = f
+	if err := s.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := s.prune(time.Now()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) load() error {
+	reader := bufio.NewReader(io.NewSectionReader(s.file, 0, 1<<62))
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(data) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		var e Entry
+		if jsonErr := json.Unmarshal(data, &e); jsonErr != nil {
+			// A line without a trailing newline is a write that was cut
+			// short, which is dropped so that the next entry starts on a
+			// line of its own; anything else is corruption.
+			if err == io.EOF {
+				if err := s.file.Truncate(offset); err != nil {
+					return err
+				}
+				break
+			}
+			return fmt.Errorf("history %s line %d: %w", s.path, line, jsonErr)
+		}
+		s.records = append(s.records, s.record(e, offset, len(data)))
+		s.nextID = max(s.nextID, e.ID+1)
+		offset += int64(len(data))
+		if err == io.EOF {
+			break
+		}
+	}
+	s.size = offset
+	return nil
+}
+
+func (s *Store) record(e Entry, offset int64, length int) record {
+	operation, ok := s.operations[e.Operation]
+	if !ok {
+		operation = e.Operation
+		s.operations[operation] = operation
+	}
+	return record{operation: operation, timestamp: e.Timestamp, offset: offset, length: length}
+}
+
+// Append records e, assigning it the next ID.
+func (s *Store) Append(e Entry) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.ID = s.nextID
+	data, err := json.Marshal(e)
+	if err != nil {
+		return Entry{}, err
+	}
+	data = append(data, '\n')
+	if _, err := s.file.Write(data); err != nil {
+		return Entry{}, err
+	}
+	s.nextID++
+	s.records = append(s.records, s.record(e, s.size, len(data)))
+	s.size += int64(len(data))
+
+	if err := s.prune(time.Now()); err != nil {
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+// prune removes the entries the retention does not allow. So that the file
+// is not rewritten on every append, up to a tenth more than MaxEntries are
+// kept before trimming, and MaxAge is only checked every pruneInterval.
+func (s *Store) prune(now time.Time) error {
+	drop := 0
+	if limit := s.retention.MaxEntries; limit > 0 && len(s.records) > limit+limit/10 {
+		drop = len(s.records) - limit
+	}
+	if s.retention.MaxAge > 0 && now.Sub(s.lastPrune) >= pruneInterval {
+		s.lastPrune = now
+		cutoff := now.Add(-s.retention.MaxAge)
+		for drop < len(s.records) && s.records[drop].timestamp.Before(cutoff) {
+			drop++
+		}
+	}
+	if drop == 0 {
+		return nil
+	}
+	return s.rewrite(s.records[drop:])
+}
+
+// Query returns the entries matching f, newest first, along with the total
+// number of matches before Offset and Limit are applied.
+func (s *Store) Query(f Filter) ([]Entry, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := max(f.Offset, 0)
+	var page []record
+	total := 0
+	for i := len(s.records) - 1; i >= 0; i-- {
+		if !f.match(s.records[i]) {
+			continue
+		}
+		if total >= start && (f.Limit <= 0 || len(page) < f.Limit) {
+			page = append(page, s.records[i])
+		}
+		total++
+	}
+
+	entries := make([]Entry, len(page))
+	for i, r := range page {
+		e, err := s.read(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries[i] = e
+	}
+	return entries, total, nil
+}
+
+// read reads the entry r indexes from the file.
+func (s *Store) read(r record) (Entry, error) {
+	data := make([]byte, r.length)
+	if _, err := s.file.ReadAt(data, r.offset); err != nil {
+		return Entry{}, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Entry{}, fmt.Errorf("history %s at offset %d: %w", s.path, r.offset, err)
+	}
+	return e, nil
+}
+
+// DeleteBefore removes every entry recorded before t and compacts the file,
+// returning the number of entries removed.
+func (s *Store) DeleteBefore(t time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var kept []record
+	for _, r := range s.records {
+		if !r.timestamp.Before(t) {
+			kept = append(kept, r)
+		}
+	}
+	deleted := len(s.records) - len(kept)
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	if err := s.rewrite(kept); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// rewrite atomically replaces the history file with the entries of records,
+// copying them from the current file, and reindexes them.
+func (s *Store) rewrite(records []record) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	kept := make([]record, len(records))
+	var offset int64
+	for i, r := range records {
+		if _, err := io.Copy(w, io.NewSectionReader(s.file, r.offset, int64(r.length))); err != nil {
+			tmp.Close()
+			return err
+		}
+		kept[i] = r
+		kept[i].offset = offset
+		offset += int64(r.length)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = f
+	s.records = kept
+	s.size = offset
+	return nil
+}
+
+// Ping reports whether the store can still record entries: its file must be
+// open and still be the file at its path.
+func (s *Store) Ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	open, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	current, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(open, current) {
+		return fmt.Errorf("history file %s was replaced", s.path)
+	}
+	return nil
+}
+
+// Close closes the underlying file.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,261 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func openTestStore(t *testing.T, path string, retention Retention) *Store {
+	t.Helper()
+	s, err := Open(path, retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// appendEntries appends n entries a minute apart, alternating between the
+// add and divide operations.
+func appendEntries(t *testing.T, s *Store, start time.Time, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		op := "add"
+		if i%2 == 1 {
+			op = "divide"
+		}
+		e := Entry{Operation: op, Operands: []any{float64(i), 1.0}, Result: float64(i + 1), Timestamp: start.Add(time.Duration(i) * time.Minute)}
+		if _, err := s.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func ids(entries []Entry) []int64 {
+	var ids []int64
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func equal(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestQuery(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "history.jsonl"), Retention{})
+	appendEntries(t, s, epoch, 10)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []int64
+		total  int
+	}{
+		{"everything, newest first", Filter{}, []int64{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, 10},
+		{"operation", Filter{Operation: "divide"}, []int64{10, 8, 6, 4, 2}, 5},
+		{"from is inclusive", Filter{From: epoch.Add(7 * time.Minute)}, []int64{10, 9, 8}, 3},
+		{"to is exclusive", Filter{To: epoch.Add(2 * time.Minute)}, []int64{2, 1}, 2},
+		{"limit", Filter{Limit: 3}, []int64{10, 9, 8}, 10},
+		{"offset and limit", Filter{Offset: 8, Limit: 3}, []int64{2, 1}, 10},
+		{"offset past the end", Filter{Offset: 20}, nil, 10},
+		{"combined", Filter{Operation: "add", From: epoch.Add(2 * time.Minute), Offset: 1, Limit: 2}, []int64{7, 5}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, total, err := s.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equal(ids(entries), tt.want) || total != tt.total {
+				t.Errorf("got IDs %v of %d, want %v of %d", ids(entries), total, tt.want, tt.total)
+			}
+		})
+	}
+
+	entries, _, err := s.Query(Filter{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := entries[0]; e.Operation != "divide" || e.Result != 10.0 || len(e.Operands) != 2 || !e.Timestamp.Equal(epoch.Add(9*time.Minute)) {
+		t.Errorf("entry was not read back intact: %+v", e)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendEntries(t, s, epoch, 3)
+	s.Close()
+
+	s = openTestStore(t, path, Retention{})
+	e, err := s.Append(Entry{Operation: "add", Timestamp: epoch.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != 4 {
+		t.Errorf("got ID %d after reopening, want 4", e.ID)
+	}
+	if _, total, _ := s.Query(Filter{}); total != 4 {
+		t.Errorf("got %d entries after reopening, want 4", total)
+	}
+}
+
+func TestOpenRecoversTruncatedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendEntries(t, s, epoch, 2)
+	s.Close()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":3,"operation":"ad`)
+	f.Close()
+
+	s = openTestStore(t, path, Retention{})
+	if _, err := s.Append(Entry{Operation: "add", Timestamp: epoch}); err != nil {
+		t.Fatal(err)
+	}
+	entries, total, err := s.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || entries[0].ID != 3 {
+		t.Errorf("got IDs %v, want the cut short entry replaced by ID 3", ids(entries))
+	}
+}
+
+func TestOpenRejectsCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	if err := os.WriteFile(path, []byte("{\"id\":1}\nnot json\n{\"id\":2}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path, Retention{}); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got %v, want an error on line 2", err)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	t.Run("max entries", func(t *testing.T) {
+		s := openTestStore(t, filepath.Join(t.TempDir(), "history.jsonl"), Retention{MaxEntries: 10})
+		// The file is only trimmed once it holds a tenth more than the cap.
+		appendEntries(t, s, time.Now(), 11)
+		if _, total, _ := s.Query(Filter{}); total != 11 {
+			t.Errorf("got %d entries below the slack, want 11", total)
+		}
+		appendEntries(t, s, time.Now(), 1)
+		entries, total, err := s.Query(Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 10 || entries[0].ID != 12 || entries[9].ID != 3 {
+			t.Errorf("got IDs %v, want the 10 newest", ids(entries))
+		}
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "history.jsonl")
+		s, err := Open(path, Retention{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		appendEntries(t, s, now.Add(-3*time.Hour), 2)
+		appendEntries(t, s, now.Add(-time.Minute), 1)
+		s.Close()
+
+		s = openTestStore(t, path, Retention{MaxAge: time.Hour})
+		entries, total, err := s.Query(Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || entries[0].ID != 3 {
+			t.Errorf("got IDs %v, want only the recent entry", ids(entries))
+		}
+	})
+}
+
+func TestDeleteBefore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s := openTestStore(t, path, Retention{})
+	appendEntries(t, s, epoch, 10)
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := s.DeleteBefore(epoch.Add(6 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 6 {
+		t.Errorf("deleted %d entries, want 6", deleted)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Errorf("file did not shrink: %d bytes, was %d", after.Size(), before.Size())
+	}
+	if deleted, err := s.DeleteBefore(epoch); err != nil || deleted != 0 {
+		t.Errorf("deleting nothing: got %d, %v", deleted, err)
+	}
+
+	// The compacted file is still appended to and read correctly.
+	if _, err := s.Append(Entry{Operation: "add", Timestamp: epoch.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	entries, total, err := s.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(ids(entries), []int64{11, 10, 9, 8, 7}) || total != 5 {
+		t.Errorf("got IDs %v, want 11 and 10 to 7", ids(entries))
+	}
+	if err := s.Ping(); err != nil {
+		t.Errorf("Ping after compaction: %v", err)
+	}
+}
+
+func TestPingDetectsReplacedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s := openTestStore(t, path, Retention{})
+	if err := s.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ping(); err == nil {
+		t.Error("Ping succeeded after the file was removed")
+	}
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ping(); err == nil {
+		t.Error("Ping succeeded after the file was replaced")
+	}
+}
@@ -1,42 +1,164 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"cloudprojects/calculator-backend-api/buildinfo"
+	"cloudprojects/calculator-backend-api/config"
+	"cloudprojects/calculator-backend-api/grpcserver"
 	"cloudprojects/calculator-backend-api/handlers"
-	"github.com/rs/cors"
+	"cloudprojects/calculator-backend-api/history"
 	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
 )
 
 func main() {
+	// Load the configuration from the config file, environment and flags
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("could not load config: %v", err)
+	}
+
 	// Set up the logger
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-
-	// Set up the HTTP server mux
-	mux := http.NewServeMux()
-
-	// Handlers to use with middleware
-	mux.Handle("/add", handlers.LoggingMiddleware(http.HandlerFunc(handlers.AddHandler(logger)), logger))
-	mux.Handle("/subtract", handlers.LoggingMiddleware(http.HandlerFunc(handlers.SubtractHandler(logger)), logger))
-	mux.Handle("/multiply", handlers.LoggingMiddleware(http.HandlerFunc(handlers.MultiplyHandler(logger)), logger))
-	mux.Handle("/divide", handlers.LoggingMiddleware(http.HandlerFunc(handlers.DivideHandler(logger)), logger))
-
-	// Set up CORS
-	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type"},
-		AllowCredentials: true,
+	logger := newLogger(cfg)
+
+	// Open the calculation history
+	store, err := history.Open(cfg.HistoryFile, history.Retention{
+		MaxEntries: cfg.HistoryMaxEntries,
+		MaxAge:     cfg.HistoryMaxAge,
 	})
+	if err != nil {
+		log.Fatalf("could not open history: %v", err)
+	}
+	defer store.Close()
+
+	// Set up token authentication, which is disabled without a config file
+	var auth *handlers.Authenticator
+	if cfg.AuthFile != "" {
+		authCfg, err := handlers.LoadAuthConfig(cfg.AuthFile)
+		if err != nil {
+			log.Fatalf("could not load auth config: %v", err)
+		}
+		if auth, err = handlers.NewAuthenticator(authCfg); err != nil {
+			log.Fatalf("could not set up authentication: %v", err)
+		}
+	} else {
+		logger.Warn("No auth file is configured, authentication is disabled")
+	}
 
-	// Wrap the mux with the CORS middleware
-	handler := c.Handler(mux)
+	// Set up the routes and middleware
+	srv, err := newServer(cfg, logger, store, auth)
+	if err != nil {
+		log.Fatalf("could not set up server: %v", err)
+	}
 
 	// Start the server
-	logger.Info("Starting server on :3000")
-	if err := http.ListenAndServe(":3000", handler); err != nil {
+	httpSrv := &http.Server{
+		Addr:              cfg.ListenAddr,
+		Handler:           srv.handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 2)
+	go func() {
+		logger.Info("Starting server", "addr", cfg.ListenAddr, "version", buildinfo.Version)
+		serveErr <- httpSrv.ListenAndServe()
+	}()
+
+	// Serve gRPC on its own port
+	var grpcSrv *grpc.Server
+	if cfg.GRPCAddr != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			log.Fatalf("could not listen for gRPC: %v", err)
+		}
+		grpcSrv = grpcserver.New(grpcserver.Config{
+			Auth:      auth,
+			IPLimiter: srv.ipLimiter,
+			Limiter:   srv.limiter,
+			History:   store,
+			Metrics:   srv.metrics,
+		}, logger)
+		go func() {
+			logger.Info("Starting gRPC server", "addr", cfg.GRPCAddr)
+			serveErr <- grpcSrv.Serve(lis)
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
 		log.Fatalf("could not start server: %v", err)
+	case <-ctx.Done():
+	}
+
+	// Stop accepting connections and wait for in-flight requests to finish,
+	// draining the HTTP and gRPC servers at the same time
+	srv.shuttingDown.Store(true)
+	logger.Info("Shutting down, draining in-flight requests", "timeout", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	grpcStopped := make(chan error, 1)
+	go func() {
+		if grpcSrv == nil {
+			grpcStopped <- nil
+			return
+		}
+		grpcStopped <- stopGRPC(shutdownCtx, grpcSrv)
+	}()
+	httpErr := httpSrv.Shutdown(shutdownCtx)
+	if err := errors.Join(httpErr, <-grpcStopped); err != nil {
+		logger.Error("Shutdown did not complete", "error", err)
+		return
+	}
+	if srv.resultCache != nil {
+		hits, misses := srv.resultCache.Stats()
+		logger.Info("Result cache statistics", "hits", hits, "misses", misses)
+	}
+	logger.Info("Server stopped")
+}
+
+// stopGRPC waits for the in-flight calls on srv to finish, or closes their
+// connections once ctx expires.
+func stopGRPC(ctx context.Context, srv *grpc.Server) error {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		srv.Stop()
+		<-stopped
+		return fmt.Errorf("gRPC: %w", ctx.Err())
+	}
+}
+
+func newLogger(cfg config.Config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+
+	if strings.EqualFold(cfg.LogFormat, "json") {
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts))
 	}
+	return slog.New(slog.NewTextHandler(os.Stdout, opts))
 }
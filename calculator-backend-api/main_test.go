@@ -0,0 +1,545 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cloudprojects/calculator-backend-api/config"
+	"cloudprojects/calculator-backend-api/handlers"
+	"cloudprojects/calculator-backend-api/history"
+	"cloudprojects/calculator-backend-api/web"
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "rewrite api-spec.yaml from the served OpenAPI document")
+
+// testAPIKey is accepted by the servers newTestServer starts.
+const testAPIKey = "test-key"
+
+// newTestServer starts the real server with authentication enabled and a
+// rate limit high enough not to get in the way.
+func newTestServer(t *testing.T) (*server, *httptest.Server) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.HistoryFile = filepath.Join(t.TempDir(), "history.jsonl")
+	cfg.RateLimit = 1e6
+	cfg.RateBurst = 1e6
+	cfg.IPRateLimit = 1e6
+	cfg.IPRateBurst = 1e6
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store, err := history.Open(cfg.HistoryFile, history.Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	auth, err := handlers.NewAuthenticator(handlers.AuthConfig{
+		APIKeys: []handlers.APIKey{{Name: "test", Key: testAPIKey}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := newServer(cfg, logger, store, auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv.handler)
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+// do sends an authenticated request with a JSON body, if body is not empty.
+func do(t *testing.T, method, url, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, data
+}
+
+// servedDocument fetches the OpenAPI document of a test server.
+func servedDocument(t *testing.T, ts *httptest.Server) []byte {
+	t.Helper()
+	resp, body := do(t, http.MethodGet, ts.URL+"/openapi.json", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /openapi.json: status %d: %s", resp.StatusCode, body)
+	}
+	return body
+}
+
+// TestRoutesAreDocumented checks that every registered route is described
+// by handlers.RouteDocs, appears in /openapi.json with each of its methods,
+// and is served for those methods.
+func TestRoutesAreDocumented(t *testing.T) {
+	srv, ts := newTestServer(t)
+	var doc struct {
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(servedDocument(t, ts), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range srv.routes {
+		ops := handlers.RouteDocs(path)
+		if len(ops) == 0 {
+			t.Errorf("route %s has no entry in handlers.RouteDocs", path)
+			continue
+		}
+		for _, op := range ops {
+			if op.Summary == "" {
+				t.Errorf("%s %s has no summary", op.Method, path)
+			}
+			if _, ok := doc.Paths[path][strings.ToLower(op.Method)]; !ok {
+				t.Errorf("%s %s is missing from /openapi.json", op.Method, path)
+			}
+			if path == "/ws" {
+				// Served only to WebSocket handshakes.
+				continue
+			}
+			resp, body := do(t, op.Method, ts.URL+path, "")
+			if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+				t.Errorf("%s %s: status %d: %s", op.Method, path, resp.StatusCode, body)
+			}
+		}
+	}
+	if len(doc.Paths) != len(srv.routes) {
+		t.Errorf("/openapi.json has %d paths, the server registers %d routes", len(doc.Paths), len(srv.routes))
+	}
+}
+
+func TestUnknownRoutes(t *testing.T) {
+	_, ts := newTestServer(t)
+	tests := []struct {
+		method, path string
+		status       int
+		code         handlers.ErrorCode
+	}{
+		{http.MethodPost, "/addd", http.StatusNotFound, handlers.CodeNotFound},
+		{http.MethodGet, "/addd", http.StatusNotFound, handlers.CodeNotFound},
+		{http.MethodGet, "/missing.js", http.StatusNotFound, handlers.CodeNotFound},
+		{http.MethodGet, "/add", http.StatusMethodNotAllowed, handlers.CodeMethodNotAllowed},
+		{http.MethodPost, "/", http.StatusMethodNotAllowed, handlers.CodeMethodNotAllowed},
+		{http.MethodOptions, "/add", http.StatusMethodNotAllowed, handlers.CodeMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		resp, body := do(t, tt.method, ts.URL+tt.path, "")
+		var result handlers.Response
+		if err := json.Unmarshal(body, &result); err != nil {
+			t.Errorf("%s %s: %v: %s", tt.method, tt.path, err, body)
+			continue
+		}
+		if resp.StatusCode != tt.status || result.Error != string(tt.code) {
+			t.Errorf("%s %s: got %d %s, want %d %s", tt.method, tt.path, resp.StatusCode, result.Error, tt.status, tt.code)
+		}
+	}
+
+	for _, path := range append([]string{"/"}, web.Assets()...) {
+		if resp, body := do(t, http.MethodGet, ts.URL+path, ""); resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: status %d: %s", path, resp.StatusCode, body)
+		}
+	}
+}
+
+// TestAPISpecIsUpToDate checks that api-spec.yaml, which clients are
+// generated from, matches the document the server serves. Run
+//
+//	go test -run TestAPISpecIsUpToDate -update
+//
+// to regenerate it.
+func TestAPISpecIsUpToDate(t *testing.T) {
+	_, ts := newTestServer(t)
+	var doc any
+	if err := json.Unmarshal(servedDocument(t, ts), &doc); err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	want.WriteString("# Code generated by go test -run TestAPISpecIsUpToDate -update. DO NOT EDIT.\n")
+	enc := yaml.NewEncoder(&want)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if err := os.WriteFile("api-spec.yaml", want.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	got, err := os.ReadFile("api-spec.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Error("api-spec.yaml does not match /openapi.json, run go test -run TestAPISpecIsUpToDate -update to regenerate it")
+	}
+}
+
+// TestAPIContract loads api-spec.yaml and checks it against the real mux:
+// every declared path is served for each of its methods, and every request
+// body built from the spec's schemas is accepted with its field names and
+// types. The values in the bodies are placeholders, so operations may still
+// reject them for other reasons.
+func TestAPIContract(t *testing.T) {
+	data, err := os.ReadFile("api-spec.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec map[string]any
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		t.Fatal(err)
+	}
+	components := spec["components"].(map[string]any)["schemas"].(map[string]any)
+	resolve := func(schema map[string]any) map[string]any {
+		for schema["$ref"] != nil {
+			schema = components[strings.TrimPrefix(schema["$ref"].(string), "#/components/schemas/")].(map[string]any)
+		}
+		return schema
+	}
+
+	_, ts := newTestServer(t)
+	paths := spec["paths"].(map[string]any)
+	if len(paths) == 0 {
+		t.Fatal("api-spec.yaml declares no paths")
+	}
+	for path, item := range paths {
+		for method, op := range item.(map[string]any) {
+			method = strings.ToUpper(method)
+			if path == "/ws" {
+				// Served only to WebSocket handshakes.
+				continue
+			}
+			bodies := []string{""}
+			if requestBody, ok := op.(map[string]any)["requestBody"].(map[string]any); ok {
+				content := requestBody["content"].(map[string]any)["application/json"].(map[string]any)
+				schema := resolve(content["schema"].(map[string]any))
+				alternatives := []any{schema}
+				if oneOf, ok := schema["oneOf"].([]any); ok {
+					alternatives = oneOf
+				}
+				bodies = bodies[:0]
+				for _, alt := range alternatives {
+					body, err := json.Marshal(sampleValue(resolve, alt.(map[string]any)))
+					if err != nil {
+						t.Fatal(err)
+					}
+					bodies = append(bodies, string(body))
+				}
+			}
+
+			for i, body := range bodies {
+				req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", "Bearer "+testAPIKey)
+				if body != "" {
+					req.Header.Set("Content-Type", "application/json")
+				}
+				if i > 0 {
+					// Further alternatives are the decimal mode bodies.
+					req.Header.Set(handlers.PrecisionHeader, "decimal")
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+
+				if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+					t.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode, data)
+					continue
+				}
+				var result handlers.Response
+				if json.Unmarshal(data, &result) != nil || result.Error != string(handlers.CodeInvalidPayload) {
+					continue
+				}
+				for _, f := range result.Fields {
+					if slices.Contains(schemaReasons, f.Reason) {
+						t.Errorf("%s %s with %s: field %q %s", method, path, body, f.Field, f.Reason)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestLegacyOperandNames(t *testing.T) {
+	_, ts := newTestServer(t)
+	tests := []struct {
+		path, body string
+		status     int
+		result     float64
+		deprecated bool
+	}{
+		{"/add", `{"a":1,"b":2}`, http.StatusOK, 3, true},
+		{"/subtract", `{"a":1,"b":2}`, http.StatusOK, -1, true},
+		{"/divide", `{"a":1,"b":4}`, http.StatusOK, 0.25, true},
+		{"/add", `{"number1":1,"number2":2}`, http.StatusOK, 3, false},
+		{"/add", `{"a":1}`, http.StatusBadRequest, 0, true},
+		{"/add", `{"a":1,"number2":2}`, http.StatusBadRequest, 0, false},
+	}
+	for _, tt := range tests {
+		resp, body := do(t, http.MethodPost, ts.URL+tt.path, tt.body)
+		var result handlers.Response
+		if err := json.Unmarshal(body, &result); err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status || result.Result != tt.result {
+			t.Errorf("%s %s: got %d %s, want %d with result %v", tt.path, tt.body, resp.StatusCode, body, tt.status, tt.result)
+		}
+		if deprecated := resp.Header.Get("Deprecation") == "true"; deprecated != tt.deprecated {
+			t.Errorf("%s %s: got deprecated %v, want %v", tt.path, tt.body, deprecated, tt.deprecated)
+		}
+	}
+}
+
+// idempotentRequest is a POST request sent with an Idempotency-Key.
+type idempotentRequest struct {
+	path        string
+	contentType string
+	body        string
+	precision   string
+}
+
+func (r idempotentRequest) send(t *testing.T, ts *httptest.Server, key string) (*http.Response, handlers.Response) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, ts.URL+r.path, strings.NewReader(r.body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	req.Header.Set(handlers.IdempotencyKeyHeader, key)
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if r.precision != "" {
+		req.Header.Set(handlers.PrecisionHeader, r.precision)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result handlers.Response
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	return resp, result
+}
+
+func TestIdempotencyKeyReuse(t *testing.T) {
+	_, ts := newTestServer(t)
+	form := "application/x-www-form-urlencoded"
+
+	tests := []struct {
+		name          string
+		first, second idempotentRequest
+		reused        bool
+	}{
+		{
+			name:   "same request",
+			first:  idempotentRequest{path: "/add?number1=1&number2=2"},
+			second: idempotentRequest{path: "/add?number1=1&number2=2"},
+		},
+		{
+			name:   "different query",
+			first:  idempotentRequest{path: "/add?number1=1&number2=2"},
+			second: idempotentRequest{path: "/add?number1=100&number2=200"},
+			reused: true,
+		},
+		{
+			name:   "different content type",
+			first:  idempotentRequest{path: "/add", contentType: form, body: "number1=1&number2=2"},
+			second: idempotentRequest{path: "/add", contentType: "application/json", body: "number1=1&number2=2"},
+			reused: true,
+		},
+		{
+			name:   "different precision",
+			first:  idempotentRequest{path: "/add", contentType: "application/json", body: `{"number1":1,"number2":2}`},
+			second: idempotentRequest{path: "/add", contentType: "application/json", body: `{"number1":1,"number2":2}`, precision: "decimal"},
+			reused: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := "key " + tt.name
+			resp, result := tt.first.send(t, ts, key)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("first request: status %d: %+v", resp.StatusCode, result)
+			}
+
+			resp, result = tt.second.send(t, ts, key)
+			if tt.reused {
+				if result.Error != string(handlers.CodeIdempotencyReuse) {
+					t.Errorf("second request: got status %d %+v, want %s", resp.StatusCode, result, handlers.CodeIdempotencyReuse)
+				}
+				return
+			}
+			if resp.Header.Get(handlers.IdempotentReplayedHeader) != "true" || result.Result != 3 {
+				t.Errorf("second request: got status %d %+v, want a replay of 3", resp.StatusCode, result)
+			}
+		})
+	}
+}
+
+// schemaReasons are the field errors of bodies that do not match the
+// server's request types.
+var schemaReasons = []string{
+	"is not a known field", "is required", "must not be null",
+	"must be a number", "must be a number or numeric string", "must be a string",
+	"must be a boolean", "must be an array", "must be an object",
+}
+
+// sampleValue builds a value that matches schema, using the first
+// alternative of a oneOf and every property of an object.
+func sampleValue(resolve func(map[string]any) map[string]any, schema map[string]any) any {
+	schema = resolve(schema)
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		return sampleValue(resolve, oneOf[0].(map[string]any))
+	}
+	switch schema["type"] {
+	case "object":
+		object := make(map[string]any)
+		properties, _ := schema["properties"].(map[string]any)
+		for name, property := range properties {
+			object[name] = sampleValue(resolve, property.(map[string]any))
+		}
+		return object
+	case "array":
+		return []any{sampleValue(resolve, schema["items"].(map[string]any))}
+	case "string":
+		return "1"
+	case "boolean":
+		return false
+	case "number", "integer":
+		return 1
+	}
+	return nil
+}
+
+func TestStopGRPC(t *testing.T) {
+	// serve starts a gRPC server whose health Watch calls run until the
+	// server stops, and opens one such call if busy.
+	serve := func(t *testing.T, busy bool) (*grpc.Server, grpc_health_v1.Health_WatchClient) {
+		lis := bufconn.Listen(1 << 20)
+		srv := grpc.NewServer()
+		grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+		go srv.Serve(lis)
+		t.Cleanup(srv.Stop)
+		if !busy {
+			return srv, nil
+		}
+
+		conn, err := grpc.NewClient("passthrough:///bufconn",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		stream, err := grpc_health_v1.NewHealthClient(conn).Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatal(err)
+		}
+		return srv, stream
+	}
+
+	t.Run("idle", func(t *testing.T) {
+		srv, _ := serve(t, false)
+		if err := stopGRPC(context.Background(), srv); err != nil {
+			t.Errorf("got %v, want a graceful stop", err)
+		}
+	})
+
+	t.Run("call outlives the timeout", func(t *testing.T) {
+		srv, stream := serve(t, true)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if err := stopGRPC(ctx, srv); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("took %v to stop", elapsed)
+		}
+		if _, err := stream.Recv(); err == nil {
+			t.Error("the call was not ended")
+		}
+	})
+}
+
+func TestHistoryRecordsPrecision(t *testing.T) {
+	_, ts := newTestServer(t)
+	do(t, http.MethodPost, ts.URL+"/add", `{"number1":0.1,"number2":0.2}`)
+	do(t, http.MethodPost, ts.URL+"/add", `{"number1":0.1,"number2":"0.2","precision":"decimal"}`)
+
+	resp, body := do(t, http.MethodGet, ts.URL+"/history", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d: %s", resp.StatusCode, body)
+	}
+	var page struct {
+		Entries []json.RawMessage `json:"entries"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(page.Entries))
+	}
+
+	for i, want := range []string{
+		`"operands":["0.1","0.2"],"precision":"decimal","result":"0.3"`,
+		`"operands":[0.1,0.2],"result":0.30000000000000004`,
+	} {
+		var entry map[string]any
+		if err := json.Unmarshal(page.Entries[i], &entry); err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]any{"operands": entry["operands"], "result": entry["result"]}
+		if precision, ok := entry["precision"]; ok {
+			got["precision"] = precision
+		}
+		data, _ := json.Marshal(got)
+		if string(data) != "{"+want+"}" {
+			t.Errorf("entry %d: got %s, want {%s}", i, data, want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package matrix implements dense linear algebra on float64 vectors and
+// matrices for the calculator's matrix endpoints.
+//
+// Matrices are slices of rows. The functions expect non-empty, rectangular
+// operands of compatible shapes, which callers are responsible for checking,
+// and panic otherwise.
+package matrix
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrSingular is returned when a matrix has no inverse, or a linear system
+// has no unique solution.
+var ErrSingular = errors.New("matrix is singular")
+
+// singularTolerance decides when a pivot counts as zero, relative to the
+// largest absolute value in the matrix. Matrices this close to singular would
+// give results dominated by rounding errors.
+const singularTolerance = 1e-12
+
+// Dot returns the dot product of two vectors of the same length.
+func Dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// Multiply returns the product of an m×k and a k×n matrix.
+func Multiply(a, b [][]float64) [][]float64 {
+	m, k, n := len(a), len(b), len(b[0])
+	result := New(m, n)
+	for i := 0; i < m; i++ {
+		for p := 0; p < k; p++ {
+			for j := 0; j < n; j++ {
+				result[i][j] += a[i][p] * b[p][j]
+			}
+		}
+	}
+	return result
+}
+
+// Transpose returns the transpose of a.
+func Transpose(a [][]float64) [][]float64 {
+	result := New(len(a[0]), len(a))
+	for i, row := range a {
+		for j, v := range row {
+			result[j][i] = v
+		}
+	}
+	return result
+}
+
+// Determinant returns the determinant of a square matrix.
+func Determinant(a [][]float64) float64 {
+	f := factorize(a)
+	det := float64(f.sign)
+	for i := range f.lu {
+		det *= f.lu[i][i]
+	}
+	return det
+}
+
+// Inverse returns the inverse of a square matrix.
+func Inverse(a [][]float64) ([][]float64, error) {
+	f := factorize(a)
+	if f.singular() {
+		return nil, ErrSingular
+	}
+	n := len(a)
+	inverse := New(n, n)
+	column := make([]float64, n)
+	for j := 0; j < n; j++ {
+		clear(column)
+		column[j] = 1
+		x := f.solve(column)
+		for i := range x {
+			inverse[i][j] = x[i]
+		}
+	}
+	return inverse, nil
+}
+
+// Solve returns x such that a·x = b, for a square matrix a.
+func Solve(a [][]float64, b []float64) ([]float64, error) {
+	f := factorize(a)
+	if f.singular() {
+		return nil, ErrSingular
+	}
+	return f.solve(b), nil
+}
+
+// New returns a rows×cols matrix of zeros.
+func New(rows, cols int) [][]float64 {
+	data := make([]float64, rows*cols)
+	m := make([][]float64, rows)
+	for i := range m {
+		m[i] = data[i*cols : (i+1)*cols : (i+1)*cols]
+	}
+	return m
+}
+
+// lu is the LU decomposition of a square matrix with partial pivoting:
+// P·A = L·U, with L and U stored together in lu and the row permutation in
+// perm.
+type lu struct {
+	lu     [][]float64
+	perm   []int
+	sign   int
+	maxAbs float64
+}
+
+func factorize(a [][]float64) *lu {
+	n := len(a)
+	f := &lu{lu: New(n, n), perm: make([]int, n), sign: 1}
+	for i, row := range a {
+		copy(f.lu[i], row)
+		f.perm[i] = i
+		for _, v := range row {
+			f.maxAbs = max(f.maxAbs, math.Abs(v))
+		}
+	}
+
+	m := f.lu
+	for k := 0; k < n; k++ {
+		pivot := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(m[i][k]) > math.Abs(m[pivot][k]) {
+				pivot = i
+			}
+		}
+		if pivot != k {
+			m[k], m[pivot] = m[pivot], m[k]
+			f.perm[k], f.perm[pivot] = f.perm[pivot], f.perm[k]
+			f.sign = -f.sign
+		}
+		if m[k][k] == 0 {
+			continue
+		}
+		for i := k + 1; i < n; i++ {
+			m[i][k] /= m[k][k]
+			for j := k + 1; j < n; j++ {
+				m[i][j] -= m[i][k] * m[k][j]
+			}
+		}
+	}
+	return f
+}
+
+func (f *lu) singular() bool {
+	for i := range f.lu {
+		if math.Abs(f.lu[i][i]) <= singularTolerance*f.maxAbs {
+			return true
+		}
+	}
+	return false
+}
+
+// solve solves a·x = b by forward and back substitution.
+func (f *lu) solve(b []float64) []float64 {
+	n := len(f.lu)
+	x := make([]float64, n)
+	for i := 0; i < n; i++ {
+		x[i] = b[f.perm[i]]
+		for j := 0; j < i; j++ {
+			x[i] -= f.lu[i][j] * x[j]
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := i + 1; j < n; j++ {
+			x[i] -= f.lu[i][j] * x[j]
+		}
+		x[i] /= f.lu[i][i]
+	}
+	return x
+}
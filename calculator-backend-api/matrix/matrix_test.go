@@ -0,0 +1,157 @@
+package matrix
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func approxEqual(a, b [][]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !approxEqualVector(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func approxEqualVector(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9*max(1, math.Abs(b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func identity(n int) [][]float64 {
+	m := New(n, n)
+	for i := range m {
+		m[i][i] = 1
+	}
+	return m
+}
+
+func TestDot(t *testing.T) {
+	if got := Dot([]float64{1, 2, 3}, []float64{4, -5, 6}); got != 12 {
+		t.Errorf("got %v, want 12", got)
+	}
+}
+
+func TestMultiplyAndTranspose(t *testing.T) {
+	a := [][]float64{{1, 2, 3}, {4, 5, 6}}
+	b := [][]float64{{7, 8}, {9, 10}, {11, 12}}
+	if got, want := Multiply(a, b), [][]float64{{58, 64}, {139, 154}}; !approxEqual(got, want) {
+		t.Errorf("Multiply: got %v, want %v", got, want)
+	}
+	if got, want := Transpose(a), [][]float64{{1, 4}, {2, 5}, {3, 6}}; !approxEqual(got, want) {
+		t.Errorf("Transpose: got %v, want %v", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	m := New(2, 3)
+	if len(m) != 2 || len(m[0]) != 3 || cap(m[0]) != 3 {
+		t.Fatalf("got %d rows of length %d and capacity %d, want 2 rows of 3", len(m), len(m[0]), cap(m[0]))
+	}
+	// Rows share a backing array, but appending to one must not overwrite
+	// the next.
+	_ = append(m[0], 9)
+	if m[1][0] != 0 {
+		t.Errorf("appending to row 0 overwrote row 1: %v", m)
+	}
+}
+
+func TestDeterminant(t *testing.T) {
+	tests := []struct {
+		name string
+		a    [][]float64
+		want float64
+	}{
+		{"1×1", [][]float64{{-3}}, -3},
+		{"identity", identity(4), 1},
+		{"2×2", [][]float64{{4, 6}, {3, 8}}, 14},
+		{"needs pivoting", [][]float64{{0, 1}, {1, 0}}, -1},
+		{"3×3", [][]float64{{6, 1, 1}, {4, -2, 5}, {2, 8, 7}}, -306},
+		{"singular", [][]float64{{1, 2}, {2, 4}}, 0},
+		{"zero column", [][]float64{{0, 1}, {0, 2}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Determinant(tt.a); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeterminantLeavesInputUnchanged(t *testing.T) {
+	a := [][]float64{{0, 1}, {1, 0}}
+	Determinant(a)
+	if !approxEqual(a, [][]float64{{0, 1}, {1, 0}}) {
+		t.Errorf("input was modified: %v", a)
+	}
+}
+
+func TestInverse(t *testing.T) {
+	tests := []struct {
+		name string
+		a    [][]float64
+		want [][]float64
+		err  error
+	}{
+		{"1×1", [][]float64{{4}}, [][]float64{{0.25}}, nil},
+		{"2×2", [][]float64{{4, 7}, {2, 6}}, [][]float64{{0.6, -0.7}, {-0.2, 0.4}}, nil},
+		{"needs pivoting", [][]float64{{0, 2}, {4, 0}}, [][]float64{{0, 0.25}, {0.5, 0}}, nil},
+		{"singular", [][]float64{{1, 2}, {2, 4}}, nil, ErrSingular},
+		{"zero", [][]float64{{0, 0}, {0, 0}}, nil, ErrSingular},
+		{"dependent rows", [][]float64{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}, nil, ErrSingular},
+		{"nearly singular", [][]float64{{1, 1}, {1, 1 + 1e-14}}, nil, ErrSingular},
+		{"small but well conditioned", [][]float64{{1e-20, 0}, {0, 1e-20}}, [][]float64{{1e20, 0}, {0, 1e20}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Inverse(tt.a)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if !approxEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if err == nil && !approxEqual(Multiply(tt.a, got), identity(len(tt.a))) {
+				t.Errorf("a·a⁻¹ = %v, want the identity", Multiply(tt.a, got))
+			}
+		})
+	}
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name string
+		a    [][]float64
+		b    []float64
+		want []float64
+		err  error
+	}{
+		{"2×2", [][]float64{{2, 1}, {1, 3}}, []float64{3, 5}, []float64{0.8, 1.4}, nil},
+		{"needs pivoting", [][]float64{{0, 1, 1}, {1, 0, 1}, {1, 1, 0}}, []float64{5, 4, 3}, []float64{1, 2, 3}, nil},
+		{"singular", [][]float64{{1, 1}, {2, 2}}, []float64{1, 2}, nil, ErrSingular},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Solve(tt.a, tt.b)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if !approxEqualVector(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
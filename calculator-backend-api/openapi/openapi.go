@@ -0,0 +1,332 @@
+// Package openapi builds an OpenAPI 3.0 document from a server's route table,
+// deriving the request and response schemas from the Go types by reflection.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Operation documents one method of a route.
+type Operation struct {
+	Method      string
+	Summary     string
+	Description string
+	// Scope is the auth scope the operation requires. Operations with a
+	// scope are also rate limited.
+	Scope      string
+	Parameters []Parameter
+	// Request is a value of the request body type, or nil if there is no
+	// body. Response is a value of the success response type. Either may be
+	// a OneOf.
+	Request  any
+	Response any
+	// ContentType is the media type of the success response,
+	// application/json by default.
+	ContentType string
+	// RequestTypes and ResponseTypes list further media types the request
+	// and response bodies may be encoded in, with the same schema.
+	RequestTypes  []string
+	ResponseTypes []string
+	// Errors lists further statuses the operation returns with the error
+	// body, besides those implied by its request body and scope.
+	Errors []int
+}
+
+// Parameter documents a query or header parameter.
+type Parameter struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	// Type and Format are the JSON schema type and format of the value.
+	Type   string
+	Format string
+}
+
+// OneOf documents a body that may have any of several types.
+type OneOf []any
+
+// Document accumulates routes and renders them as an OpenAPI document.
+type Document struct {
+	title     string
+	version   string
+	errorBody any
+	security  bool
+	paths     map[string][]Operation
+}
+
+// New returns an empty document. errorBody is a value of the type every
+// error response has. security marks operations with a scope as requiring
+// a bearer token.
+func New(title, version string, errorBody any, security bool) *Document {
+	return &Document{
+		title:     title,
+		version:   version,
+		errorBody: errorBody,
+		security:  security,
+		paths:     make(map[string][]Operation),
+	}
+}
+
+// Add documents the operations served at path. It panics if there are none,
+// so that every registered route must be documented.
+func (d *Document) Add(path string, ops ...Operation) {
+	if len(ops) == 0 {
+		panic(fmt.Sprintf("openapi: route %s has no documented operations", path))
+	}
+	for _, op := range ops {
+		if op.Method == "" || op.Summary == "" {
+			panic(fmt.Sprintf("openapi: route %s has an operation without a method or summary", path))
+		}
+	}
+	d.paths[path] = append(d.paths[path], ops...)
+}
+
+// JSON renders the document.
+func (d *Document) JSON() ([]byte, error) {
+	g := &generator{schemas: make(map[string]any)}
+	errorSchema := g.schema(reflect.TypeOf(d.errorBody))
+
+	paths := make(map[string]any, len(d.paths))
+	for path, ops := range d.paths {
+		item := make(map[string]any, len(ops))
+		for _, op := range ops {
+			item[strings.ToLower(op.Method)] = d.operation(g, op, errorSchema)
+		}
+		paths[path] = item
+	}
+
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": d.title, "version": d.version},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "description": "An API key or an HS256 JWT."},
+			},
+		},
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (d *Document) operation(g *generator, op Operation, errorSchema map[string]any) map[string]any {
+	out := map[string]any{"summary": op.Summary}
+	if op.Description != "" {
+		out["description"] = op.Description
+	}
+
+	var params []any
+	for _, p := range op.Parameters {
+		schema := map[string]any{"type": p.Type}
+		if p.Format != "" {
+			schema["format"] = p.Format
+		}
+		param := map[string]any{"name": p.Name, "in": p.In, "required": p.Required, "schema": schema}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		params = append(params, param)
+	}
+	if params != nil {
+		out["parameters"] = params
+	}
+
+	statuses := slices.Clone(op.Errors)
+	if op.Request != nil {
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content":  content(append([]string{"application/json"}, op.RequestTypes...), g.body(op.Request)),
+		}
+		statuses = append(statuses, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType)
+	}
+	if op.Scope != "" {
+		statuses = append(statuses, http.StatusTooManyRequests)
+		if d.security {
+			out["security"] = []any{map[string]any{"bearerAuth": []string{}}}
+			statuses = append(statuses, http.StatusUnauthorized, http.StatusForbidden)
+			out["description"] = strings.TrimSpace(op.Description + "\n\nRequires the `" + op.Scope + "` scope.")
+		}
+	}
+
+	contentType := op.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	success := map[string]any{"description": http.StatusText(http.StatusOK)}
+	if op.Response != nil {
+		success["content"] = content(append([]string{contentType}, op.ResponseTypes...), g.body(op.Response))
+	}
+	responses := map[string]any{"200": success}
+	for _, status := range statuses {
+		responses[fmt.Sprint(status)] = map[string]any{
+			"description": http.StatusText(status),
+			"content":     content(append([]string{"application/json"}, op.ResponseTypes...), errorSchema),
+		}
+	}
+	out["responses"] = responses
+	return out
+}
+
+// content describes a body with the given schema in each of mediaTypes.
+func content(mediaTypes []string, schema map[string]any) map[string]any {
+	out := make(map[string]any, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		out[mediaType] = map[string]any{"schema": schema}
+	}
+	return out
+}
+
+// generator derives JSON schemas from Go types, collecting named types as
+// reusable components.
+type generator struct {
+	schemas map[string]any
+}
+
+// body returns the schema of a request or response body.
+func (g *generator) body(v any) map[string]any {
+	if alternatives, ok := v.(OneOf); ok {
+		var schemas []any
+		for _, alt := range alternatives {
+			schemas = append(schemas, g.schema(reflect.TypeOf(alt)))
+		}
+		return map[string]any{"oneOf": schemas}
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	numberType = reflect.TypeOf(json.Number(""))
+)
+
+func (g *generator) schema(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case numberType:
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "number"},
+			map[string]any{"type": "string", "description": "A number as a string, which keeps its exact value."},
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := g.schema(t.Elem())
+		if _, ref := schema["$ref"]; !ref {
+			schema["nullable"] = true
+		}
+		return schema
+	case reflect.Struct, reflect.Slice:
+		if t.Name() != "" {
+			return g.component(t)
+		}
+		return g.inline(t)
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	}
+	panic("openapi: unsupported type " + t.String())
+}
+
+// component registers a named type under components/schemas and returns a
+// reference to it.
+func (g *generator) component(t reflect.Type) map[string]any {
+	name := t.Name()
+	if _, ok := g.schemas[name]; !ok {
+		g.schemas[name] = nil // placeholder, for recursive types
+		g.schemas[name] = g.inline(t)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func (g *generator) inline(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Slice {
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	}
+
+	properties := make(map[string]any)
+	var required []string
+	g.fields(t, properties, &required)
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fields adds the JSON fields of struct type t to properties. Fields without
+// omitempty that are not pointers are required, matching the server's
+// strict request decoding.
+func (g *generator) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" {
+			g.fields(f.Type, properties, required)
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = g.schema(f.Type)
+		if !strings.Contains(","+opts+",", ",omitempty,") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// Handler serves the document as JSON.
+func (d *Document) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := d.JSON()
+		if err != nil {
+			http.Error(w, "could not render the OpenAPI document", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// docsPage is a self-contained documentation page, so that it loads no
+// scripts from third parties.
+//
+//go:embed docs.html
+var docsPage string
+
+// DocsHandler serves an interactive documentation page for the document at
+// specURL.
+func DocsHandler(title, specURL string) http.Handler {
+	page := strings.NewReplacer("{{title}}", html.EscapeString(title), "{{spec}}", specURL).Replace(docsPage)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	})
+}
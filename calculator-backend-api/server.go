@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"cloudprojects/calculator-backend-api/buildinfo"
+	"cloudprojects/calculator-backend-api/config"
+	"cloudprojects/calculator-backend-api/handlers"
+	"cloudprojects/calculator-backend-api/history"
+	"cloudprojects/calculator-backend-api/openapi"
+	"cloudprojects/calculator-backend-api/web"
+	"github.com/rs/cors"
+	"golang.org/x/exp/slog"
+)
+
+// server is the HTTP side of the calculator: the handler serving every
+// route, and the state main needs when shutting down.
+type server struct {
+	handler http.Handler
+	api     *openapi.Document
+	// routes lists the registered route patterns, in registration order.
+	routes      []string
+	resultCache *handlers.ResultCache
+	// limiter, ipLimiter and metrics are shared with the gRPC server.
+	limiter, ipLimiter *handlers.RateLimiter
+	metrics            *handlers.Metrics
+	// shuttingDown makes /readyz report the server not ready.
+	shuttingDown atomic.Bool
+}
+
+// newServer sets up the routes and middleware. auth is nil when
+// authentication is disabled.
+func newServer(cfg config.Config, logger *slog.Logger, store *history.Store, auth *handlers.Authenticator) (*server, error) {
+	s := &server{}
+
+	// Set up per-client rate limiting, and a limit per IP address on
+	// requests before they are authenticated, so that tokens cannot be
+	// guessed at an unlimited rate
+	var err error
+	s.limiter, err = handlers.NewRateLimiter(handlers.RateLimitConfig{
+		Rate:           cfg.RateLimit,
+		Burst:          cfg.RateBurst,
+		TrustedProxies: cfg.TrustedProxies,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not set up rate limiting: %w", err)
+	}
+	s.ipLimiter, err = handlers.NewRateLimiter(handlers.RateLimitConfig{
+		Rate:           cfg.IPRateLimit,
+		Burst:          cfg.IPRateBurst,
+		TrustedProxies: cfg.TrustedProxies,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not set up IP rate limiting: %w", err)
+	}
+
+	// Protected handlers are rate limited per IP address, authenticated,
+	// then rate limited per client. Without authentication clients are
+	// identified by IP address anyway, so the first limit is left out.
+	protect := func(h http.Handler, scope string) http.Handler {
+		h = handlers.RateLimitMiddleware(h, s.limiter, logger)
+		if auth == nil {
+			return h
+		}
+		h = handlers.AuthMiddleware(h, auth, scope, logger)
+		return handlers.RateLimitMiddleware(h, s.ipLimiter, logger)
+	}
+
+	// Set up the HTTP server mux. Every route is described in the OpenAPI
+	// document, and all but the documentation and metrics routes are
+	// instrumented for metrics.
+	mux := http.NewServeMux()
+	s.api = openapi.New("Calculator API", buildinfo.Version, handlers.Response{}, auth != nil)
+	// Routes are served only for their documented methods, other methods get
+	// a 405 listing the allowed ones. The 405 handlers are registered per
+	// method, since a method-less pattern would conflict with the catch-all
+	// 404 handler. A pattern ending in "{$}" matches its path exactly and is
+	// documented without the suffix.
+	var undocumented []string
+	route := func(pattern string, h http.Handler) {
+		path := strings.TrimSuffix(pattern, "{$}")
+		s.routes = append(s.routes, path)
+		ops := handlers.RouteDocs(path)
+		if len(ops) == 0 {
+			undocumented = append(undocumented, path)
+			return
+		}
+		s.api.Add(path, ops...)
+		var methods []string
+		for _, op := range ops {
+			mux.Handle(op.Method+" "+pattern, h)
+			methods = append(methods, op.Method)
+		}
+		notAllowed := handlers.MethodNotAllowedHandler(logger, methods...)
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions} {
+			if !slices.Contains(methods, method) {
+				mux.Handle(method+" "+pattern, notAllowed)
+			}
+		}
+	}
+	s.metrics = handlers.NewMetrics()
+	handle := func(pattern string, h http.Handler) {
+		route(pattern, handlers.MetricsMiddleware(h, s.metrics, pattern))
+	}
+
+	// Calculation handlers are recorded in the history as well as logged.
+	// Repeated requests are answered from the idempotency store or the
+	// result cache. Their responses, errors included, are encoded as the
+	// Accept header asks.
+	idempotency := handlers.NewIdempotencyStore(cfg.IdempotencyMaxKeys, cfg.IdempotencyTTL)
+	if cfg.ResultCacheSize > 0 {
+		s.resultCache = handlers.NewResultCache(cfg.ResultCacheSize, logger)
+	}
+	calculation := func(h http.HandlerFunc) http.Handler {
+		var next http.Handler = handlers.HistoryMiddleware(h, store, logger)
+		if s.resultCache != nil {
+			next = handlers.ResultCacheMiddleware(next, s.resultCache)
+		}
+		next = handlers.IdempotencyMiddleware(next, idempotency, logger)
+		return handlers.NegotiationMiddleware(protect(handlers.LoggingMiddleware(next, logger), "calculate"), logger)
+	}
+
+	// Handlers to use with middleware
+	handle("/add", calculation(handlers.AddHandler(logger)))
+	handle("/subtract", calculation(handlers.SubtractHandler(logger)))
+	handle("/multiply", calculation(handlers.MultiplyHandler(logger)))
+	handle("/divide", calculation(handlers.DivideHandler(logger)))
+	handle("/sum", calculation(handlers.SumHandler(logger)))
+	handle("/evaluate", calculation(handlers.EvaluateHandler(logger)))
+	for _, op := range handlers.OperationNames() {
+		handle("/"+op, calculation(handlers.OperationHandler(logger, op)))
+	}
+	for _, op := range handlers.ComplexOperationNames() {
+		handle("/complex/"+op, calculation(handlers.ComplexHandler(logger, op)))
+	}
+	for _, op := range handlers.BigIntOperationNames() {
+		handle("/bigint/"+op, calculation(handlers.BigIntHandler(logger, op)))
+	}
+	for _, op := range handlers.MatrixOperationNames() {
+		handle("/matrix/"+op, calculation(handlers.MatrixHandler(logger, op, cfg.MaxMatrixElements)))
+	}
+	handle("/convert-units", calculation(handlers.ConvertUnitsHandler(logger)))
+	handle("/batch", calculation(handlers.BatchHandler(logger, cfg.MaxBatchSize)))
+	handle("/history", protect(handlers.LoggingMiddleware(http.HandlerFunc(handlers.HistoryHandler(logger, store)), logger), "history"))
+	// Sessions are long-lived, so they are authenticated and rate limited
+	// when they connect but not counted in the request metrics.
+	sessions := handlers.NewSessionManager(handlers.SessionConfig{
+		TTL:            cfg.SessionTTL,
+		MaxSessions:    cfg.MaxSessions,
+		AllowedOrigins: cfg.CORSOrigins,
+	}, logger)
+	route("/ws", protect(handlers.LoggingMiddleware(sessions.Handler(logger), logger), "calculate"))
+	// Probes and build info are neither authenticated nor rate limited. The
+	// server reports itself not ready once it starts shutting down.
+	route("/healthz", handlers.HealthHandler(logger))
+	route("/readyz", handlers.ReadinessHandler(logger,
+		handlers.ReadinessCheck{Name: "history", Check: func(context.Context) error { return store.Ping() }},
+		handlers.ReadinessCheck{Name: "shutdown", Check: func(context.Context) error {
+			if s.shuttingDown.Load() {
+				return errors.New("server is shutting down")
+			}
+			return nil
+		}},
+	))
+	route("/version", handlers.VersionHandler(logger))
+	route("/metrics", s.metrics.Handler())
+	route("/openapi.json", s.api.Handler())
+	route("/docs", openapi.DocsHandler("Calculator API", "/openapi.json"))
+
+	// The web UI is served from the root, and calls the API on the same
+	// origin. Its assets are not part of the API, so are left undocumented.
+	// Any other path is not found.
+	ui := web.Handler()
+	route("/{$}", ui)
+	for _, asset := range web.Assets() {
+		mux.Handle(http.MethodGet+" "+asset, ui)
+	}
+	mux.Handle("/", handlers.NotFoundHandler(logger))
+
+	if len(undocumented) > 0 {
+		return nil, fmt.Errorf("routes have no API documentation: %s", strings.Join(undocumented, ", "))
+	}
+
+	// Set up CORS. Credentials are not needed since authentication uses
+	// bearer tokens rather than cookies.
+	c := cors.New(cors.Options{
+		AllowedOrigins:   cfg.CORSOrigins,
+		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", handlers.PrecisionHeader, handlers.RequestIDHeader, handlers.IdempotencyKeyHeader},
+		ExposedHeaders:   []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "Deprecation", handlers.RequestIDHeader, handlers.IdempotentReplayedHeader},
+		AllowCredentials: false,
+	})
+
+	// Wrap the mux with the CORS middleware and a request body size limit,
+	// tag every request with an ID and recover from panics in any handler
+	s.handler = handlers.RequestIDMiddleware(handlers.RecoveryMiddleware(c.Handler(handlers.BodyLimitMiddleware(mux, cfg.MaxBodyBytes)), logger))
+	return s, nil
+}
@@ -0,0 +1,209 @@
+// Package units converts values between units of length, mass, temperature
+// and data size.
+//
+// Every unit is defined by a linear mapping onto the base unit of its
+// dimension: base = (value + offset) * scale. The offset is only non-zero for
+// temperature scales whose zero is not absolute zero. Conversions are computed
+// with exact rational arithmetic, so that e.g. 100 °C is exactly 212 °F.
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Dimension is a kind of quantity. Units convert only within a dimension.
+type Dimension string
+
+const (
+	Length      Dimension = "length"
+	Mass        Dimension = "mass"
+	Temperature Dimension = "temperature"
+	Data        Dimension = "data"
+)
+
+// ErrUnknownUnit is returned for unit names that are not in the registry.
+var ErrUnknownUnit = errors.New("unknown unit")
+
+// ErrBelowAbsoluteZero is returned for temperatures below absolute zero.
+var ErrBelowAbsoluteZero = errors.New("temperature is below absolute zero")
+
+// IncompatibleError is returned when converting between dimensions.
+type IncompatibleError struct {
+	From, To Unit
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("cannot convert %s (%s) to %s (%s)", e.From.Name, e.From.Dimension, e.To.Name, e.To.Dimension)
+}
+
+// Unit is a registered unit. Name is its canonical name.
+type Unit struct {
+	Name      string
+	Symbol    string
+	Dimension Dimension
+
+	scale  *big.Rat
+	offset *big.Rat
+}
+
+func (u Unit) toBase(v *big.Rat) *big.Rat {
+	base := new(big.Rat).Add(v, u.offset)
+	return base.Mul(base, u.scale)
+}
+
+func (u Unit) fromBase(base *big.Rat) *big.Rat {
+	v := new(big.Rat).Quo(base, u.scale)
+	return v.Sub(v, u.offset)
+}
+
+// rat parses an exact decimal or fraction such as "0.3048" or "5/9".
+func rat(s string) *big.Rat {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		panic("units: invalid constant " + s)
+	}
+	return r
+}
+
+// prefix is a multiplier that can be applied to a unit's name and symbol.
+type prefix struct {
+	name, symbol string
+	factor       string
+}
+
+var siPrefixes = []prefix{
+	{"tera", "T", "1e12"},
+	{"giga", "G", "1e9"},
+	{"mega", "M", "1e6"},
+	{"kilo", "k", "1e3"},
+	{"hecto", "h", "1e2"},
+	{"deca", "da", "1e1"},
+	{"deci", "d", "1e-1"},
+	{"centi", "c", "1e-2"},
+	{"milli", "m", "1e-3"},
+	{"micro", "µ", "1e-6"},
+	{"nano", "n", "1e-9"},
+	{"pico", "p", "1e-12"},
+}
+
+// dataPrefixes are the decimal and binary multiples used for data sizes.
+var dataPrefixes = []prefix{
+	{"kilo", "k", "1e3"},
+	{"mega", "M", "1e6"},
+	{"giga", "G", "1e9"},
+	{"tera", "T", "1e12"},
+	{"peta", "P", "1e15"},
+	{"kibi", "Ki", "1024"},
+	{"mebi", "Mi", "1048576"},
+	{"gibi", "Gi", "1073741824"},
+	{"tebi", "Ti", "1099511627776"},
+	{"pebi", "Pi", "1125899906842624"},
+}
+
+var (
+	// bySymbol indexes units by their case-sensitive symbol, and byName by
+	// their lower case name, plural and aliases.
+	bySymbol = map[string]Unit{}
+	byName   = map[string]Unit{}
+)
+
+func init() {
+	// Length, based on the metre.
+	withPrefixes(siPrefixes, Unit{Name: "metre", Symbol: "m", Dimension: Length, scale: rat("1")}, "meter")
+	register(Unit{Name: "inch", Symbol: "in", Dimension: Length, scale: rat("0.0254")}, "inches")
+	register(Unit{Name: "foot", Symbol: "ft", Dimension: Length, scale: rat("0.3048")}, "feet")
+	register(Unit{Name: "yard", Symbol: "yd", Dimension: Length, scale: rat("0.9144")})
+	register(Unit{Name: "mile", Symbol: "mi", Dimension: Length, scale: rat("1609.344")})
+	register(Unit{Name: "nautical mile", Symbol: "nmi", Dimension: Length, scale: rat("1852")})
+
+	// Mass, based on the kilogram.
+	withPrefixes(siPrefixes, Unit{Name: "gram", Symbol: "g", Dimension: Mass, scale: rat("1e-3")}, "gramme")
+	register(Unit{Name: "tonne", Symbol: "t", Dimension: Mass, scale: rat("1000")}, "metric ton", "metric tons")
+	register(Unit{Name: "ounce", Symbol: "oz", Dimension: Mass, scale: rat("0.028349523125")})
+	register(Unit{Name: "pound", Symbol: "lb", Dimension: Mass, scale: rat("0.45359237")}, "lbs")
+	register(Unit{Name: "stone", Symbol: "st", Dimension: Mass, scale: rat("6.35029318")})
+	register(Unit{Name: "short ton", Symbol: "ton", Dimension: Mass, scale: rat("907.18474")})
+	register(Unit{Name: "long ton", Symbol: "LT", Dimension: Mass, scale: rat("1016.0469088")})
+
+	// Temperature, based on the kelvin.
+	register(Unit{Name: "kelvin", Symbol: "K", Dimension: Temperature, scale: rat("1")})
+	register(Unit{Name: "celsius", Symbol: "°C", Dimension: Temperature, scale: rat("1"), offset: rat("273.15")}, "C", "degC", "degree celsius", "degrees celsius")
+	register(Unit{Name: "fahrenheit", Symbol: "°F", Dimension: Temperature, scale: rat("5/9"), offset: rat("459.67")}, "F", "degF", "degree fahrenheit", "degrees fahrenheit")
+	register(Unit{Name: "rankine", Symbol: "°R", Dimension: Temperature, scale: rat("5/9")}, "R", "degR")
+
+	// Data size, based on the byte.
+	withPrefixes(dataPrefixes, Unit{Name: "byte", Symbol: "B", Dimension: Data, scale: rat("1")})
+	withPrefixes(dataPrefixes, Unit{Name: "bit", Symbol: "b", Dimension: Data, scale: rat("0.125")})
+}
+
+// register adds u to the registry under its name, plural, symbol and aliases.
+func register(u Unit, aliases ...string) {
+	if u.offset == nil {
+		u.offset = new(big.Rat)
+	}
+	bySymbol[u.Symbol] = u
+	for _, name := range append([]string{u.Name, u.Name + "s"}, aliases...) {
+		byName[strings.ToLower(name)] = u
+	}
+}
+
+// withPrefixes registers u along with every prefixed multiple of it. The
+// aliases are spellings of the name, so get a plural and the prefixes too.
+func withPrefixes(prefixes []prefix, u Unit, aliases ...string) {
+	var spellings []string
+	for _, alias := range aliases {
+		spellings = append(spellings, alias, alias+"s")
+	}
+	register(u, spellings...)
+	for _, p := range prefixes {
+		prefixed := Unit{
+			Name:      p.name + u.Name,
+			Symbol:    p.symbol + u.Symbol,
+			Dimension: u.Dimension,
+			scale:     new(big.Rat).Mul(u.scale, rat(p.factor)),
+			offset:    u.offset,
+		}
+		var prefixedAliases []string
+		for _, alias := range spellings {
+			prefixedAliases = append(prefixedAliases, p.name+alias)
+		}
+		register(prefixed, prefixedAliases...)
+	}
+}
+
+// Lookup finds a unit by its symbol, which is case sensitive, or by its name,
+// plural or an alias, which are not. The Greek letter mu and "u" are accepted
+// for the micro prefix.
+func Lookup(name string) (Unit, error) {
+	name = strings.TrimSpace(name)
+	if u, ok := bySymbol[name]; ok {
+		return u, nil
+	}
+	for _, micro := range []string{"μ", "u"} {
+		if rest, ok := strings.CutPrefix(name, micro); ok {
+			if u, ok := bySymbol["µ"+rest]; ok {
+				return u, nil
+			}
+		}
+	}
+	if u, ok := byName[strings.ToLower(name)]; ok {
+		return u, nil
+	}
+	return Unit{}, fmt.Errorf("%w %q", ErrUnknownUnit, name)
+}
+
+// Convert converts value from one unit to another of the same dimension.
+func Convert(value float64, from, to Unit) (float64, error) {
+	if from.Dimension != to.Dimension {
+		return 0, &IncompatibleError{From: from, To: to}
+	}
+	base := from.toBase(new(big.Rat).SetFloat64(value))
+	if from.Dimension == Temperature && base.Sign() < 0 {
+		return 0, ErrBelowAbsoluteZero
+	}
+	result, _ := to.fromBase(base).Float64()
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"m", "metre"},
+		{"metre", "metre"},
+		{"metres", "metre"},
+		{"meter", "metre"},
+		{"meters", "metre"},
+		{"Meters", "metre"},
+		{" km ", "kilometre"},
+		{"kilometers", "kilometre"},
+		{"kilometres", "kilometre"},
+		{"µm", "micrometre"},
+		{"μm", "micrometre"},
+		{"um", "micrometre"},
+		{"feet", "foot"},
+		{"inches", "inch"},
+		{"gramme", "gram"},
+		{"grammes", "gram"},
+		{"kilogrammes", "kilogram"},
+		{"kg", "kilogram"},
+		{"lbs", "pound"},
+		{"metric tons", "tonne"},
+		{"degrees celsius", "celsius"},
+		{"°F", "fahrenheit"},
+		{"degC", "celsius"},
+		{"KiB", "kibibyte"},
+		{"Mb", "megabit"},
+		{"MB", "megabyte"},
+	}
+	for _, tt := range tests {
+		u, err := Lookup(tt.name)
+		if err != nil {
+			t.Errorf("Lookup(%q): %v", tt.name, err)
+			continue
+		}
+		if u.Name != tt.want {
+			t.Errorf("Lookup(%q) = %s, want %s", tt.name, u.Name, tt.want)
+		}
+	}
+
+	for _, name := range []string{"", "parsec", "metress", "lbss", "M"} {
+		if _, err := Lookup(name); !errors.Is(err, ErrUnknownUnit) {
+			t.Errorf("Lookup(%q): got %v, want ErrUnknownUnit", name, err)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{1, "km", "m", 1000},
+		{1, "mile", "km", 1.609344},
+		{12, "in", "ft", 1},
+		{1, "kg", "lb", 2.2046226218487757},
+		{1, "t", "kg", 1000},
+		{100, "°C", "°F", 212},
+		{-40, "°C", "°F", -40},
+		{32, "°F", "°C", 0},
+		{0, "K", "°C", -273.15},
+		{0, "°C", "K", 273.15},
+		{491.67, "°R", "°F", 32},
+		{1, "KiB", "B", 1024},
+		{8, "b", "B", 1},
+		{1, "GB", "MB", 1000},
+	}
+	for _, tt := range tests {
+		from, err := Lookup(tt.from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		to, err := Lookup(tt.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Convert(tt.value, from, to)
+		if err != nil {
+			t.Errorf("Convert(%v %s to %s): %v", tt.value, tt.from, tt.to, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9*math.Max(1, math.Abs(tt.want)) {
+			t.Errorf("Convert(%v %s to %s) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	lookup := func(name string) Unit {
+		u, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	_, err := Convert(1, lookup("m"), lookup("kg"))
+	var incompatible *IncompatibleError
+	if !errors.As(err, &incompatible) || incompatible.From.Dimension != Length || incompatible.To.Dimension != Mass {
+		t.Errorf("metres to kilograms: got %v, want an IncompatibleError", err)
+	}
+
+	for _, tt := range []struct {
+		value float64
+		unit  string
+	}{{-1, "K"}, {-273.16, "°C"}, {-459.68, "°F"}, {-0.01, "°R"}} {
+		if _, err := Convert(tt.value, lookup(tt.unit), lookup("K")); !errors.Is(err, ErrBelowAbsoluteZero) {
+			t.Errorf("%v %s: got %v, want ErrBelowAbsoluteZero", tt.value, tt.unit, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+"use strict";
+
+const HISTORY_KEY = "calculator.history";
+const TOKEN_KEY = "calculator.token";
+const HISTORY_SIZE = 20;
+
+const symbols = {
+  add: "+",
+  subtract: "−",
+  multiply: "×",
+  divide: "÷",
+  power: "^",
+  modulo: "mod",
+  root: "root",
+  log: "log base",
+};
+
+const $ = (id) => document.getElementById(id);
+
+// call posts body to an API endpoint and returns the decoded response,
+// throwing an Error carrying the API's error code and field errors.
+async function call(path, body) {
+  const headers = { "Content-Type": "application/json" };
+  const token = $("token").value.trim();
+  if (token) {
+    headers.Authorization = `Bearer ${token}`;
+  }
+
+  let resp;
+  try {
+    resp = await fetch(path, { method: "POST", headers, body: JSON.stringify(body) });
+  } catch (err) {
+    throw Object.assign(new Error("Could not reach the server."), { code: "network_error" });
+  }
+
+  let data = null;
+  try {
+    data = await resp.json();
+  } catch {
+    // Non-JSON errors, e.g. from a proxy.
+  }
+  if (!resp.ok || (data && data.error)) {
+    const message = (data && data.message) || resp.statusText || `HTTP ${resp.status}`;
+    throw Object.assign(new Error(message), {
+      code: (data && data.error) || `http_${resp.status}`,
+      fields: (data && data.fields) || [],
+      position: data && data.position,
+    });
+  }
+  return data;
+}
+
+function showResult(result) {
+  $("result").textContent = result;
+  $("error").hidden = true;
+}
+
+function showError(err) {
+  $("result").textContent = "\u00a0";
+  const box = $("error");
+  box.replaceChildren();
+
+  const summary = document.createElement("div");
+  summary.textContent = `${err.code}: ${err.message}`;
+  if (err.position !== undefined && err.position !== null) {
+    summary.textContent += ` (at position ${err.position})`;
+  }
+  box.append(summary);
+
+  if (err.fields && err.fields.length) {
+    const list = document.createElement("ul");
+    for (const field of err.fields) {
+      const item = document.createElement("li");
+      item.textContent = field.field ? `${field.field} ${field.reason}` : field.reason;
+      list.append(item);
+    }
+    box.append(list);
+  }
+  box.hidden = false;
+}
+
+function loadHistory() {
+  try {
+    return JSON.parse(localStorage.getItem(HISTORY_KEY)) || [];
+  } catch {
+    return [];
+  }
+}
+
+function saveHistory(entries) {
+  localStorage.setItem(HISTORY_KEY, JSON.stringify(entries.slice(0, HISTORY_SIZE)));
+}
+
+function record(description, outcome, failed) {
+  saveHistory([{ description, outcome, failed, at: new Date().toISOString() }, ...loadHistory()]);
+  renderHistory();
+}
+
+function renderHistory() {
+  const list = $("history");
+  list.replaceChildren();
+  for (const entry of loadHistory()) {
+    const item = document.createElement("li");
+    item.textContent = `${entry.description} = ${entry.outcome}`;
+    item.title = new Date(entry.at).toLocaleString();
+    if (entry.failed) {
+      item.className = "failed";
+    }
+    list.append(item);
+  }
+}
+
+async function run(path, body, description) {
+  try {
+    const data = await call(path, body);
+    showResult(data.result);
+    record(description, data.result, false);
+  } catch (err) {
+    showError(err);
+    record(description, err.code, true);
+  }
+}
+
+$("operation-form").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const operation = $("operation").value;
+  const a = Number($("number1").value);
+  const b = Number($("number2").value);
+  const body = operation === "divide" ? { dividend: a, divisor: b } : { number1: a, number2: b };
+  run(`/${operation}`, body, `${a} ${symbols[operation]} ${b}`);
+});
+
+$("expression-form").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const expression = $("expression").value;
+  run("/evaluate", { expression }, expression);
+});
+
+$("clear-history").addEventListener("click", () => {
+  localStorage.removeItem(HISTORY_KEY);
+  renderHistory();
+});
+
+$("token").value = localStorage.getItem(TOKEN_KEY) || "";
+$("token").addEventListener("change", () => {
+  localStorage.setItem(TOKEN_KEY, $("token").value.trim());
+});
+
+renderHistory();
@@ -0,0 +1,42 @@
+// Package web embeds the calculator's single-page web UI.
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"path"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the UI, with index.html at the root.
+func Handler() http.Handler {
+	return http.FileServerFS(root())
+}
+
+// Assets returns the paths of the files the UI loads, other than index.html,
+// which is served at the root.
+func Assets() []string {
+	var assets []string
+	err := fs.WalkDir(root(), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || name == "index.html" {
+			return err
+		}
+		assets = append(assets, path.Join("/", name))
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	return assets
+}
+
+func root() fs.FS {
+	root, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return root
+}